@@ -0,0 +1,65 @@
+package kv
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Backup writes a consistent backup of the whole database to the given file,
+// which is created or truncated.
+func (c *Client) Backup(path string) (err error) {
+	start := time.Now()
+	defer func() { c.track("backup", path, 0, start, err) }()
+	if c.closed {
+		return c.closedError()
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to create backup file %s: %w", path, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	w := bufio.NewWriter(f)
+	if _, err := c.db.Backup(w, 0); err != nil {
+		return wrapError(err)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("unable to write backup file %s: %w", path, err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("unable to write backup file %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// maxPendingRestoreWrites is the number of pending writes allowed while
+// loading a backup.
+const maxPendingRestoreWrites = 256
+
+// Restore loads the backup written by Backup in the given file into the
+// database. Keys of the backup overwrite the existing ones.
+func (c *Client) Restore(path string) (err error) {
+	start := time.Now()
+	defer func() { c.track("restore", path, 0, start, err) }()
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+	return c.restore(path)
+}
+
+// restore loads the backup in the given file into the database.
+func (s *store) restore(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("unable to open backup file %s: %w", path, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if err := s.db.Load(bufio.NewReader(f), maxPendingRestoreWrites); err != nil {
+		return wrapError(err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package kv
+
+import (
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/grafana/sobek"
+)
+
+// barrierKey returns the key holding the number of arrivals at the given
+// barrier.
+func barrierKey(name string) []byte {
+	return internalKey("barrier", name)
+}
+
+// BarrierWait returns a promise resolved once the given number of
+// participants, including the caller, arrived at the given barrier, or
+// rejected with a Timeout error if they don't within the given timeout in
+// milliseconds. A timeout lower or equal to 0 means waiting until the end of
+// the test. The barrier can be reused: the next participants wait for the
+// next group to be complete.
+func (c *Client) BarrierWait(name string, count int64, timeoutMs int64) *sobek.Promise {
+	key := barrierKey(c.scoped(name))
+	var target int64
+	return c.waitPromise("barrierWait", name, key, timeoutMs, func() (bool, error) {
+		if target == 0 {
+			if count <= 0 {
+				return false, newInvalidArgumentError("barrier count must be positive, got %d", count)
+			}
+			var arrival int64
+			err := c.updateLocked(func(txn *badger.Txn) error {
+				var err error
+				arrival, err = nextCounter(txn, key)
+				return err
+			})
+			if err != nil {
+				return false, err
+			}
+			// The arrival belongs to the group completed by the next
+			// multiple of count.
+			target = ((arrival-1)/count + 1) * count
+		}
+		var arrivals int64
+		err := c.view(func(txn *badger.Txn) error {
+			var err error
+			arrivals, err = readCount(txn, key)
+			return err
+		})
+		return arrivals >= target, err
+	}, func() interface{} {
+		return true
+	})
+}
@@ -0,0 +1,255 @@
+package kv
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// defaultVisibilityTimeout is the time a claimed item stays invisible when
+// Claim is called without a visibility timeout.
+const defaultVisibilityTimeout = 30 * time.Second
+
+// ClaimedItem is an item claimed from a queue, which must be acknowledged
+// with its id once processed.
+type ClaimedItem struct {
+	ID       string      `js:"id"`
+	Value    interface{} `js:"value"`
+	Attempts int         `js:"attempts"`
+}
+
+// claim is a claimed item as stored under the claims of its queue, along
+// with the time it becomes visible again and the number of times it was
+// claimed.
+type claim struct {
+	visibleAt time.Time
+	attempts  uint32
+	value     []byte
+}
+
+// encode returns the stored form of the claim.
+func (cl claim) encode() []byte {
+	b := make([]byte, 12, 12+len(cl.value))
+	binary.BigEndian.PutUint64(b[:8], uint64(cl.visibleAt.UnixMilli()))
+	binary.BigEndian.PutUint32(b[8:12], cl.attempts)
+	return append(b, cl.value...)
+}
+
+// decodeClaim decodes the stored form of a claim.
+func decodeClaim(b []byte) claim {
+	return claim{
+		visibleAt: time.UnixMilli(int64(binary.BigEndian.Uint64(b[:8]))),
+		attempts:  binary.BigEndian.Uint32(b[8:12]),
+		value:     b[12:],
+	}
+}
+
+// claimKey returns the key of the claim of the given queue with the given
+// sequence number.
+func claimKey(queue string, n int64) []byte {
+	return itemKey(internalKey("claims", queue), encodeIndex(n))
+}
+
+// parseClaimID returns the queue and the sequence number of the given claim
+// id, formatted as queue#seq.
+func parseClaimID(id string) (string, int64, error) {
+	i := strings.LastIndexByte(id, '#')
+	if i < 0 {
+		return "", 0, newInvalidArgumentError("invalid claim id %q", id)
+	}
+	n, err := strconv.ParseInt(id[i+1:], 10, 64)
+	if err != nil {
+		return "", 0, newInvalidArgumentError("invalid claim id %q", id)
+	}
+	return id[:i], n, nil
+}
+
+// Claim takes the item at the front of the given queue and hides it for the
+// given visibility timeout, a number of seconds or a duration string, 30
+// seconds by default. Unless acknowledged with Ack in the meantime, the item
+// becomes visible again once the timeout expires, and is claimed again, with
+// the same id, before the other items of the queue. Items of which the
+// visibility timeout expired after deadLetterAfter claims are moved to the
+// dead-letter queue instead of being claimed again. It returns null if no
+// item is visible.
+func (c *Client) Claim(queue string, visibilityTimeout interface{}) (_ interface{}, err error) {
+	var cl claim
+	start := time.Now()
+	defer func() { c.track("claim", queue, len(cl.value), start, err) }()
+	timeout, err := toDuration(visibilityTimeout, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if timeout <= 0 {
+		timeout = defaultVisibilityTimeout
+	}
+
+	var n int64
+	var valueType byte
+	name := c.scoped(queue)
+	err = c.updateSeq("queue", name, func(txn *badger.Txn, s *seq) error {
+		now := time.Now()
+		var found bool
+		var err error
+		for {
+			n, cl, valueType, found, err = findVisibleClaim(txn, name, now)
+			if err != nil || !found {
+				break
+			}
+			if c.opts.deadLetterAfter <= 0 || int(cl.attempts) < c.opts.deadLetterAfter {
+				break
+			}
+			if err := deadLetter(txn, name, n, cl, valueType); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if !found {
+			n = 0
+			if err := promoteDueItems(txn, name, s, now); err != nil {
+				return err
+			}
+			item, val, err := s.pop(txn, true)
+			if err != nil || item == nil {
+				return err
+			}
+			if n, err = nextCounter(txn, internalKey("claims", name)); err != nil {
+				return err
+			}
+			cl, valueType = claim{value: val}, item.UserMeta()
+		}
+		cl.visibleAt = now.Add(timeout)
+		cl.attempts++
+		return txn.SetEntry(badger.NewEntry(claimKey(name, n), cl.encode()).WithMeta(valueType))
+	})
+	if err != nil || n == 0 {
+		return nil, err
+	}
+	return &ClaimedItem{
+		ID:       queue + "#" + strconv.FormatInt(n, 10),
+		Value:    c.decodeValue(valueType, cl.value),
+		Attempts: int(cl.attempts),
+	}, nil
+}
+
+// findVisibleClaim returns the first claim of the given queue whose
+// visibility timeout expired at the given time, along with its sequence
+// number and the type of its value, and false if there is none.
+func findVisibleClaim(txn *badger.Txn, queue string, now time.Time) (int64, claim, byte, bool, error) {
+	prefix := itemKey(internalKey("claims", queue), nil)
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		item := it.Item()
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return 0, claim{}, 0, false, err
+		}
+		cl := decodeClaim(val)
+		if cl.visibleAt.After(now) {
+			continue
+		}
+		return decodeIndex(item.Key()[len(prefix):]), cl, item.UserMeta(), true, nil
+	}
+	return 0, claim{}, 0, false, nil
+}
+
+// Ack acknowledges the processing of the claimed item with the given id,
+// removing it for good. It returns false if the item was already
+// acknowledged.
+func (c *Client) Ack(id string) (_ bool, err error) {
+	start := time.Now()
+	defer func() { c.track("ack", id, 0, start, err) }()
+	queue, n, err := parseClaimID(id)
+	if err != nil {
+		return false, err
+	}
+	queue = c.scoped(queue)
+	var found bool
+	c.seqMu.Lock()
+	defer c.seqMu.Unlock()
+	err = c.update(func(txn *badger.Txn) error {
+		_, err := txn.Get(claimKey(queue, n))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+		return txn.Delete(claimKey(queue, n))
+	})
+	return found, err
+}
+
+// Nack reports the failed processing of the claimed item with the given id,
+// making it visible again right away instead of at the end of its visibility
+// timeout. Items nacked after having been claimed deadLetterAfter times are
+// moved to the dead-letter queue instead. It returns false if the item was
+// already acknowledged.
+func (c *Client) Nack(id string) (_ bool, err error) {
+	start := time.Now()
+	defer func() { c.track("nack", id, 0, start, err) }()
+	queue, n, err := parseClaimID(id)
+	if err != nil {
+		return false, err
+	}
+	queue = c.scoped(queue)
+	var found bool
+	c.seqMu.Lock()
+	defer c.seqMu.Unlock()
+	err = c.update(func(txn *badger.Txn) error {
+		item, err := txn.Get(claimKey(queue, n))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		cl := decodeClaim(val)
+		if c.opts.deadLetterAfter > 0 && int(cl.attempts) >= c.opts.deadLetterAfter {
+			return deadLetter(txn, queue, n, cl, item.UserMeta())
+		}
+		cl.visibleAt = time.Now()
+		return txn.SetEntry(badger.NewEntry(claimKey(queue, n), cl.encode()).WithMeta(item.UserMeta()))
+	})
+	return found, err
+}
+
+// dlqName returns the name of the dead-letter queue of the given queue.
+func dlqName(queue string) string {
+	return queue + ":dlq"
+}
+
+// deadLetter moves the claimed item with the given sequence number to the
+// back of the dead-letter queue of the given queue.
+func deadLetter(txn *badger.Txn, queue string, n int64, cl claim, valueType byte) error {
+	dlq, err := loadSeq(txn, "queue", dlqName(queue))
+	if err != nil {
+		return err
+	}
+	if err := dlq.push(txn, cl.value, valueType, false); err != nil {
+		return err
+	}
+	return txn.Delete(claimKey(queue, n))
+}
+
+// DlqEntries returns the values moved to the dead-letter queue of the given
+// queue, oldest first. The dead-letter queue is a regular queue named
+// "<queue>:dlq", which can also be drained with Dequeue.
+func (c *Client) DlqEntries(queue string) ([]interface{}, error) {
+	return c.rangeSeq("queue", dlqName(c.scoped(queue)), 0, -1)
+}
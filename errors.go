@@ -0,0 +1,108 @@
+package kv
+
+import (
+	"errors"
+	"fmt"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// ErrorName is the name of an error thrown by the kv module, scripts can
+// branch on it to handle each kind of failure.
+type ErrorName string
+
+const (
+	// KeyNotFoundError is thrown when a key that does not exist is required.
+	KeyNotFoundError ErrorName = "KeyNotFound"
+
+	// ConflictError is thrown when a transaction conflicts with another
+	// concurrent transaction. The operation can safely be retried.
+	ConflictError ErrorName = "Conflict"
+
+	// InvalidArgumentError is thrown when a method is called with an invalid
+	// argument, or when a stored value cannot be used by the operation.
+	InvalidArgumentError ErrorName = "InvalidArgument"
+
+	// ReadOnlyError is thrown when writing to a database opened in read-only
+	// mode.
+	ReadOnlyError ErrorName = "ReadOnly"
+
+	// DatabaseClosedError is thrown when the database is used after being
+	// closed.
+	DatabaseClosedError ErrorName = "DatabaseClosed"
+
+	// TimeoutError is thrown, or the promise rejected with, when an
+	// operation waiting for a condition times out.
+	TimeoutError ErrorName = "Timeout"
+
+	// DatabaseError is thrown for any other failure of the database.
+	DatabaseError ErrorName = "DatabaseError"
+)
+
+// Error is the error thrown to scripts by the kv module. Scripts find it in
+// the value property of the exception:
+//
+//	try {
+//	  client.ttl("missing");
+//	} catch (e) {
+//	  if (e.value.name === "KeyNotFound") { ... }
+//	}
+type Error struct {
+	// Name identifies the kind of error.
+	Name ErrorName `js:"name"`
+
+	// Message describes the error.
+	Message string `js:"message"`
+
+	// Key is the key the operation failed on, if any.
+	Key string `js:"key"`
+
+	cause error
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return string(e.Name) + ": " + e.Message
+}
+
+// Unwrap returns the Badger error at the origin of the error, if any.
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// newKeyNotFoundError returns the error for a missing key.
+func newKeyNotFoundError(key string) *Error {
+	return &Error{Name: KeyNotFoundError, Message: fmt.Sprintf("key %s not found", key), Key: key}
+}
+
+// newInvalidArgumentError returns the error for an invalid argument.
+func newInvalidArgumentError(format string, args ...interface{}) *Error {
+	return &Error{Name: InvalidArgumentError, Message: fmt.Sprintf(format, args...)}
+}
+
+// wrapError converts an error returned by Badger into an *Error. Errors that
+// already are an *Error are returned unchanged.
+func wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var kvErr *Error
+	if errors.As(err, &kvErr) {
+		return err
+	}
+
+	name := DatabaseError
+	switch {
+	case errors.Is(err, badger.ErrKeyNotFound):
+		name = KeyNotFoundError
+	case errors.Is(err, badger.ErrConflict):
+		name = ConflictError
+	case errors.Is(err, badger.ErrDBClosed):
+		name = DatabaseClosedError
+	case errors.Is(err, badger.ErrEmptyKey), errors.Is(err, badger.ErrInvalidKey),
+		errors.Is(err, badger.ErrTxnTooBig), errors.Is(err, badger.ErrInvalidRequest):
+		name = InvalidArgumentError
+	}
+	return &Error{Name: name, Message: err.Error(), cause: err}
+}
@@ -34,12 +34,8 @@ export function generator() {
 export function results() {
   console.log(client.get("hello_1"));
   client.delete("hello_1");
-  try {
-    var keyDeleteValue = client.get("hello_1");
-    console.log(typeof (keyDeleteValue));
-  }
-  catch (err) {
-    console.log("empty value", err);
+  if (client.get("hello_1") === null) {
+    console.log("empty value");
   }
   var r = client.viewPrefix("hello");
   for (var key in r) {
@@ -48,10 +44,5 @@ export function results() {
 }
 
 export function ttl() {
-  try {
-    console.log(client.get('ttl_1'));
-  }
-  catch (err) {
-    console.log("empty value", err);
-  }
+  console.log(client.get('ttl_1') ?? "empty value");
 }
\ No newline at end of file
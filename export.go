@@ -0,0 +1,156 @@
+package kv
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// ExportJSON returns the entries where the key starts with the given prefix
+// as an object mapping each key to its value, ready to be embedded in the
+// handleSummary output. Values are decoded according to their type, as Get
+// returns them.
+func (c *Client) ExportJSON(prefix string) (_ map[string]interface{}, err error) {
+	start := time.Now()
+	defer func() { c.track("exportJSON", prefix, 0, start, err) }()
+	m := make(map[string]interface{})
+	ns := c.namespace()
+	err = c.view(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(ns + prefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			if isInternalKey(it.Item().Key()) || expired(it.Item()) {
+				continue
+			}
+			item := it.Item()
+			valCopy, valueType, err := itemValue(item)
+			if err != nil {
+				return err
+			}
+			m[string(item.Key()[len(ns):])] = c.decodeValue(valueType, valCopy)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ExportOptions holds the settings of ExportFile.
+type ExportOptions struct {
+	// Prefix selects the keys exported.
+	Prefix string `js:"prefix"`
+	// Format is either "csv", for key and value columns, or "jsonl", for one
+	// {"key": ..., "value": ...} object per line. It defaults to "csv" for
+	// .csv files, and to "jsonl" otherwise.
+	Format string `js:"format"`
+}
+
+// ExportFile streams the entries where the key starts with the given prefix
+// to the given file, which is created or truncated, and returns the number
+// of entries exported.
+func (c *Client) ExportFile(path string, opts ExportOptions) (_ int, err error) {
+	start := time.Now()
+	defer func() { c.track("exportFile", path, 0, start, err) }()
+	format, err := exportFormat(path, opts.Format)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("unable to create export file %s: %w", path, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	w := bufio.NewWriter(f)
+	write := exportJSONL(w)
+	var cw *csv.Writer
+	if format == "csv" {
+		cw = csv.NewWriter(w)
+		write = func(key, value []byte) error {
+			return cw.Write([]string{string(key), string(value)})
+		}
+		if err := cw.Write([]string{"key", "value"}); err != nil {
+			return 0, fmt.Errorf("unable to write export file %s: %w", path, err)
+		}
+	}
+
+	var count int
+	ns := c.namespace()
+	err = c.view(func(txn *badger.Txn) error {
+		iopts := badger.DefaultIteratorOptions
+		iopts.Prefix = []byte(ns + opts.Prefix)
+		it := txn.NewIterator(iopts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			if isInternalKey(it.Item().Key()) || expired(it.Item()) {
+				continue
+			}
+			item := it.Item()
+			val, _, err := itemValue(item)
+			if err != nil {
+				return err
+			}
+			if err := write(item.Key()[len(ns):], val); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if cw != nil {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return 0, fmt.Errorf("unable to write export file %s: %w", path, err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return 0, fmt.Errorf("unable to write export file %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return 0, fmt.Errorf("unable to write export file %s: %w", path, err)
+	}
+	return count, nil
+}
+
+// exportFormat returns the format of the given file, guessed from its
+// extension unless given explicitly.
+func exportFormat(path string, format string) (string, error) {
+	switch format {
+	case "csv", "jsonl":
+		return format, nil
+	case "":
+		if strings.ToLower(filepath.Ext(path)) == ".csv" {
+			return "csv", nil
+		}
+		return "jsonl", nil
+	default:
+		return "", newInvalidArgumentError("unsupported export format %q", format)
+	}
+}
+
+// exportJSONL returns a function writing an entry to w as a JSONL line.
+func exportJSONL(w *bufio.Writer) func(key, value []byte) error {
+	enc := json.NewEncoder(w)
+	return func(key, value []byte) error {
+		return enc.Encode(struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		}{string(key), string(value)})
+	}
+}
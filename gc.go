@@ -0,0 +1,72 @@
+package kv
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultGCDiscardRatio is the discard ratio used when none is given.
+const defaultGCDiscardRatio = 0.5
+
+// RunGC runs the value log garbage collection, rewriting every value log
+// file of which at least discardRatio can be discarded, 0.5 by default. It
+// returns the number of files rewritten.
+func (c *Client) RunGC(discardRatio float64) (int, error) {
+	if err := c.checkWritable(); err != nil {
+		return 0, err
+	}
+	if discardRatio == 0 {
+		discardRatio = defaultGCDiscardRatio
+	}
+	if discardRatio <= 0 || discardRatio >= 1 {
+		return 0, newInvalidArgumentError("discard ratio must be between 0 and 1, got %v", discardRatio)
+	}
+	return c.runGC(discardRatio)
+}
+
+// runGC runs the value log garbage collection until there is nothing left
+// to rewrite, and returns the number of files rewritten.
+func (s *store) runGC(discardRatio float64) (int, error) {
+	if s.opts.inMemory {
+		return 0, nil
+	}
+	atomic.AddUint64(&s.gcRuns, 1)
+	rewritten := 0
+	for {
+		err := s.db.RunValueLogGC(discardRatio)
+		if errors.Is(err, badger.ErrNoRewrite) || errors.Is(err, badger.ErrRejected) {
+			return rewritten, nil
+		}
+		if err != nil {
+			return rewritten, wrapError(err)
+		}
+		rewritten++
+		atomic.AddUint64(&s.gcRewritten, 1)
+	}
+}
+
+// scheduleGC runs the value log garbage collection every interval, until
+// the store is closed.
+func (s *store) scheduleGC(interval time.Duration, discardRatio float64, logger logrus.FieldLogger) {
+	if discardRatio == 0 {
+		discardRatio = defaultGCDiscardRatio
+	}
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				if _, err := s.runGC(discardRatio); err != nil {
+					logger.WithError(err).Warnf("value log GC of kv %q failed", s.name)
+				}
+			}
+		}
+	}()
+}
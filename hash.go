@@ -0,0 +1,125 @@
+package kv
+
+import (
+	"errors"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// hashFieldKey returns the key of the given field of the hash of the given
+// key.
+func hashFieldKey(key string, field string) []byte {
+	return itemKey(internalKey("hash", key), []byte(field))
+}
+
+// HSet sets the given field of the hash of the given key with the given
+// value, of any type accepted by Set, leaving the other fields untouched.
+func (c *Client) HSet(key string, field string, value interface{}) (err error) {
+	var data []byte
+	start := time.Now()
+	defer func() { c.track("hSet", key, len(data), start, err) }()
+	data, valueType, err := encodeValue(value)
+	if err != nil {
+		return &Error{Name: InvalidArgumentError, Message: err.Error(), Key: key}
+	}
+	err = c.update(func(txn *badger.Txn) error {
+		return txn.SetEntry(badger.NewEntry(hashFieldKey(c.scoped(key), field), data).WithMeta(valueType))
+	})
+	return err
+}
+
+// HGet returns the value of the given field of the hash of the given key, or
+// null if the field does not exist.
+func (c *Client) HGet(key string, field string) (_ interface{}, err error) {
+	var valCopy []byte
+	start := time.Now()
+	defer func() { c.track("hGet", key, len(valCopy), start, err) }()
+	var valueType byte
+	var found bool
+	err = c.view(func(txn *badger.Txn) error {
+		item, err := txn.Get(hashFieldKey(c.scoped(key), field))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found, valueType = true, item.UserMeta()
+		valCopy, err = item.ValueCopy(nil)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return c.decodeValue(valueType, valCopy), nil
+}
+
+// HGetAll returns the fields of the hash of the given key as an object, read
+// in a single transaction. It returns an empty object if the hash does not
+// exist.
+func (c *Client) HGetAll(key string) (_ map[string]interface{}, err error) {
+	start := time.Now()
+	defer func() { c.track("hGetAll", key, 0, start, err) }()
+	m := make(map[string]interface{})
+	err = c.view(func(txn *badger.Txn) error {
+		prefix := hashFieldKey(c.scoped(key), "")
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			valCopy, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			m[string(item.Key()[len(prefix):])] = c.decodeValue(item.UserMeta(), valCopy)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// HDel deletes the given field of the hash of the given key, and returns
+// false if the field did not exist.
+func (c *Client) HDel(key string, field string) (_ bool, err error) {
+	start := time.Now()
+	defer func() { c.track("hDel", key, 0, start, err) }()
+	var found bool
+	err = c.update(func(txn *badger.Txn) error {
+		k := hashFieldKey(c.scoped(key), field)
+		_, err := txn.Get(k)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+		return txn.Delete(k)
+	})
+	return found, err
+}
+
+// HLen returns the number of fields of the hash of the given key.
+func (c *Client) HLen(key string) (_ int, err error) {
+	start := time.Now()
+	defer func() { c.track("hLen", key, 0, start, err) }()
+	var count int
+	err = c.view(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = hashFieldKey(c.scoped(key), "")
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
@@ -0,0 +1,408 @@
+package kv
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// importProgressInterval is the number of entries between two progress
+// reports of an import.
+const importProgressInterval = 100000
+
+// ImportOptions holds the settings of ImportFile.
+type ImportOptions struct {
+	// Format is either "json", for a single object mapping keys to values,
+	// or "jsonl", for one {"key": ..., "value": ...} object per line.
+	// It defaults to "jsonl" for .jsonl and .ndjson files, and to "json"
+	// otherwise.
+	Format string `js:"format"`
+}
+
+// ImportFile streams the entries of the given JSON or JSONL file into the
+// database through a single write batch, and returns the number of entries
+// imported. String values are stored as is, the other values as JSON.
+func (c *Client) ImportFile(path string, opts ImportOptions) (_ int, err error) {
+	start := time.Now()
+	defer func() { c.track("importFile", path, 0, start, err) }()
+	if err := c.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	format, err := importFormat(path, opts.Format)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("unable to open import file %s: %w", path, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	return c.importJSON(bufio.NewReader(f), format, c.namespace(), path, c.logger())
+}
+
+// URLImportOptions holds the settings of ImportURL.
+type URLImportOptions struct {
+	// Format is either "json" or "jsonl", as for ImportFile, and is guessed
+	// from the extension of the URL path when empty.
+	Format string `js:"format"`
+	// Headers are added to the request.
+	Headers map[string]string `js:"headers"`
+	// Timeout bounds the download, as a number of milliseconds or a duration
+	// string. It defaults to defaultImportTimeout.
+	Timeout interface{} `js:"timeout"`
+}
+
+// defaultImportTimeout bounds the downloads of the imports from HTTP URLs,
+// so that a stalled server doesn't hang the test.
+const defaultImportTimeout = time.Minute
+
+// ImportURL streams the JSON or JSONL entries downloaded from the given URL
+// into the database, as ImportFile does, and returns the number of entries
+// imported.
+func (c *Client) ImportURL(rawURL string, opts URLImportOptions) (_ int, err error) {
+	start := time.Now()
+	defer func() { c.track("importURL", rawURL, 0, start, err) }()
+	if err := c.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return 0, newInvalidArgumentError("invalid import URL %q", rawURL)
+	}
+	format, err := importFormat(u.Path, opts.Format)
+	if err != nil {
+		return 0, err
+	}
+	timeout, err := toDuration(opts.Timeout, time.Millisecond)
+	if err != nil {
+		return 0, err
+	}
+	if timeout < 0 {
+		return 0, newInvalidArgumentError("timeout must not be negative")
+	}
+	if timeout == 0 {
+		timeout = defaultImportTimeout
+	}
+
+	ctx := c.vu.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	body, err := openURL(ctx, u.String(), opts.Headers, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = body.Close() }()
+
+	return c.importJSON(bufio.NewReader(body), format, c.namespace(), u.Redacted(), c.logger())
+}
+
+// openURL sends a GET request with the given headers to the given URL and
+// returns the body of the response, which must be read within the given
+// timeout, 0 meaning no timeout.
+func openURL(ctx context.Context, rawURL string, headers map[string]string, timeout time.Duration) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, newInvalidArgumentError("invalid import URL %q: %s", rawURL, err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download %s: %w", req.URL.Redacted(), err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("unable to download %s: %s", req.URL.Redacted(), resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// CSVImportOptions holds the settings of ImportCSV.
+type CSVImportOptions struct {
+	// KeyColumn is the header of the column holding the keys. It defaults
+	// to the first column.
+	KeyColumn string `js:"keyColumn"`
+	// ValueColumns are the headers of the columns stored in the values. They
+	// default to all the columns but the key one.
+	ValueColumns []string `js:"valueColumns"`
+	// Prefix is prepended to the keys.
+	Prefix string `js:"prefix"`
+}
+
+// ImportCSV imports the rows of the given CSV file, of which the first row
+// holds the column headers, and returns the number of entries imported. Each
+// row is stored under the value of its key column as a JSON object mapping
+// the headers of the value columns to their values.
+func (c *Client) ImportCSV(path string, opts CSVImportOptions) (_ int, err error) {
+	start := time.Now()
+	defer func() { c.track("importCSV", path, 0, start, err) }()
+	if err := c.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("unable to open import file %s: %w", path, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	r := csv.NewReader(bufio.NewReader(f))
+	r.ReuseRecord = true
+	header, err := r.Read()
+	if err != nil {
+		return 0, newInvalidArgumentError("unable to read the header of %s: %s", path, err)
+	}
+	header = append([]string(nil), header...)
+
+	keyIndex, valueIndexes, err := csvColumns(header, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	imp := c.newImporter(c.namespace(), path, c.logger())
+	defer imp.cancel()
+
+	row := make(map[string]string, len(valueIndexes))
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, newInvalidArgumentError("invalid CSV in %s: %s", path, err)
+		}
+		for _, i := range valueIndexes {
+			row[header[i]] = record[i]
+		}
+		value, err := json.Marshal(row)
+		if err != nil {
+			return 0, err
+		}
+		if err := imp.set(opts.Prefix+record[keyIndex], value, valueTypeJSON); err != nil {
+			return 0, err
+		}
+	}
+	return imp.flush()
+}
+
+// csvColumns returns the indexes of the key and value columns of a CSV file
+// with the given header.
+func csvColumns(header []string, opts CSVImportOptions) (int, []int, error) {
+	indexes := make(map[string]int, len(header))
+	for i, name := range header {
+		indexes[name] = i
+	}
+
+	keyIndex := 0
+	if opts.KeyColumn != "" {
+		i, ok := indexes[opts.KeyColumn]
+		if !ok {
+			return 0, nil, newInvalidArgumentError("unknown key column %q", opts.KeyColumn)
+		}
+		keyIndex = i
+	}
+
+	var valueIndexes []int
+	if len(opts.ValueColumns) == 0 {
+		for i := range header {
+			if i != keyIndex {
+				valueIndexes = append(valueIndexes, i)
+			}
+		}
+		return keyIndex, valueIndexes, nil
+	}
+	for _, name := range opts.ValueColumns {
+		i, ok := indexes[name]
+		if !ok {
+			return 0, nil, newInvalidArgumentError("unknown value column %q", name)
+		}
+		valueIndexes = append(valueIndexes, i)
+	}
+	return keyIndex, valueIndexes, nil
+}
+
+// ImportEnv imports the environment variables of the test of which the name
+// starts with the given prefix, such as "KVSEED_", stripping the prefix from
+// the keys. It returns the number of entries imported.
+func (c *Client) ImportEnv(prefix string) (_ int, err error) {
+	start := time.Now()
+	defer func() { c.track("importEnv", prefix, 0, start, err) }()
+	if err := c.checkWritable(); err != nil {
+		return 0, err
+	}
+	if prefix == "" {
+		return 0, newInvalidArgumentError("importEnv requires a prefix")
+	}
+
+	env := c.env
+	if env == nil {
+		env = make(map[string]string)
+		for _, kv := range os.Environ() {
+			if name, value, ok := strings.Cut(kv, "="); ok {
+				env[name] = value
+			}
+		}
+	}
+
+	imp := c.newImporter(c.namespace(), "env", c.logger())
+	defer imp.cancel()
+	for name, value := range env {
+		key := strings.TrimPrefix(name, prefix)
+		if key == name || key == "" {
+			continue
+		}
+		if err := imp.set(key, []byte(value), valueTypeString); err != nil {
+			return 0, err
+		}
+	}
+	return imp.flush()
+}
+
+// importFormat returns the format of the given file, guessed from its
+// extension unless given explicitly.
+func importFormat(path string, format string) (string, error) {
+	switch format {
+	case "json", "jsonl":
+		return format, nil
+	case "":
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".jsonl", ".ndjson":
+			return "jsonl", nil
+		default:
+			return "json", nil
+		}
+	default:
+		return "", newInvalidArgumentError("unsupported import format %q", format)
+	}
+}
+
+// importJSON imports the JSON or JSONL entries read from r under the given
+// namespace.
+func (s *store) importJSON(
+	r io.Reader, format string, ns string, source string, logger logrus.FieldLogger,
+) (int, error) {
+	imp := s.newImporter(ns, source, logger)
+	defer imp.cancel()
+
+	dec := json.NewDecoder(r)
+	if format == "jsonl" {
+		for {
+			var line struct {
+				Key   string          `json:"key"`
+				Value json.RawMessage `json:"value"`
+			}
+			err := dec.Decode(&line)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return 0, newInvalidArgumentError("invalid JSONL entry %d in %s: %s", imp.count+1, source, err)
+			}
+			value, valueType := importValue(line.Value)
+			if err := imp.set(line.Key, value, valueType); err != nil {
+				return 0, err
+			}
+		}
+		return imp.flush()
+	}
+
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return 0, newInvalidArgumentError("%s does not contain a JSON object", source)
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return 0, newInvalidArgumentError("invalid JSON in %s: %s", source, err)
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return 0, newInvalidArgumentError("invalid JSON in %s: %s", source, err)
+		}
+		data, valueType := importValue(value)
+		if err := imp.set(tok.(string), data, valueType); err != nil {
+			return 0, err
+		}
+	}
+	return imp.flush()
+}
+
+// importValue returns the bytes to store for the given JSON value along with
+// its type: the content of strings, and the JSON encoding of the other
+// values.
+func importValue(raw json.RawMessage) ([]byte, byte) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return []byte(s), valueTypeString
+	}
+	return raw, valueTypeJSON
+}
+
+// importer writes imported entries through a single write batch, logging
+// the progress of the import.
+type importer struct {
+	s      *store
+	wb     *badger.WriteBatch
+	ns     string
+	source string
+	logger logrus.FieldLogger
+	count  int
+}
+
+// newImporter returns an importer writing the entries read from the given
+// source to the store, under the given namespace.
+func (s *store) newImporter(ns string, source string, logger logrus.FieldLogger) *importer {
+	return &importer{
+		s:      s,
+		wb:     s.db.NewWriteBatch(),
+		ns:     ns,
+		source: source,
+		logger: logger.WithFields(logrus.Fields{"kv": s.name, "source": source}),
+	}
+}
+
+// set adds the given entry, with the given value type, to the import.
+func (imp *importer) set(key string, value []byte, valueType byte) error {
+	if err := imp.wb.SetEntry(imp.s.newEntry([]byte(imp.ns+key), value, valueType)); err != nil {
+		return wrapError(err)
+	}
+	imp.count++
+	if imp.count%importProgressInterval == 0 {
+		imp.logger.WithField("entries", imp.count).Info("kv import in progress")
+	}
+	return nil
+}
+
+// flush commits the imported entries and returns their number.
+func (imp *importer) flush() (int, error) {
+	if err := imp.wb.Flush(); err != nil {
+		return 0, wrapError(err)
+	}
+	imp.logger.WithField("entries", imp.count).Info("kv import done")
+	return imp.count, nil
+}
+
+// cancel discards the entries not flushed yet.
+func (imp *importer) cancel() {
+	imp.wb.Cancel()
+}
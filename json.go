@@ -0,0 +1,200 @@
+package kv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// SetJSON sets the given key with the JSON encoding of the given value, which
+// Get returns decoded.
+func (c *Client) SetJSON(key string, value interface{}) (err error) {
+	var data []byte
+	start := time.Now()
+	defer func() { c.track("set", key, len(data), start, err) }()
+	data, err = json.Marshal(value)
+	if err != nil {
+		return newInvalidArgumentError("unable to encode the value of key %q as JSON: %s", key, err)
+	}
+	err = c.update(func(txn *badger.Txn) error {
+		return txn.SetEntry(c.newEntry(c.key(key), data, valueTypeJSON))
+	})
+	return err
+}
+
+// GetJSON returns the decoded JSON value of the given key, or null if the
+// key does not exist and the client wasn't created with throwOnMissing.
+func (c *Client) GetJSON(key string) (_ interface{}, err error) {
+	var valCopy []byte
+	start := time.Now()
+	defer func() { c.track("get", key, len(valCopy), start, err) }()
+	var found bool
+	valCopy, found, err = c.readValue(key)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return c.missing(key)
+	}
+	return decodeJSON(key, valCopy)
+}
+
+// decodeJSON decodes the JSON value of the given key.
+func decodeJSON(key string, value []byte) (interface{}, error) {
+	var v interface{}
+	if err := json.Unmarshal(value, &v); err != nil {
+		return nil, &Error{Name: InvalidArgumentError, Message: "value is not valid JSON: " + err.Error(), Key: key}
+	}
+	return v, nil
+}
+
+// UpdateJSONPath sets the field at the given dot-separated path, such as
+// "a.b.c" or "items.0.id", in the JSON document of the given key, within a
+// single transaction. The missing objects along the path, and the document
+// itself, are created. An existing document keeps its type and its TTL.
+func (c *Client) UpdateJSONPath(key string, path string, value interface{}) (err error) {
+	var size int
+	start := time.Now()
+	defer func() { c.track("set", key, size, start, err) }()
+	fields, err := splitJSONPath(path)
+	if err != nil {
+		return err
+	}
+	err = c.update(func(txn *badger.Txn) error {
+		var doc interface{} = map[string]interface{}{}
+		var valueType byte
+		item, err := getItem(txn, c.key(key))
+		switch {
+		case errors.Is(err, badger.ErrKeyNotFound):
+			item = nil
+		case err != nil:
+			return err
+		default:
+			var valCopy []byte
+			valCopy, valueType, err = itemValue(item)
+			if err != nil {
+				return err
+			}
+			if doc, err = decodeJSON(key, valCopy); err != nil {
+				return err
+			}
+		}
+		if doc, err = setJSONPath(doc, fields, value); err != nil {
+			return &Error{Name: InvalidArgumentError, Message: err.Error(), Key: key}
+		}
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return newInvalidArgumentError("unable to encode the value of key %q as JSON: %s", key, err)
+		}
+		size = len(data)
+		if item == nil {
+			return txn.SetEntry(c.newEntry(c.key(key), data, valueTypeJSON))
+		}
+		e := badger.NewEntry(c.key(key), data).WithMeta(valueType)
+		deadline, err := itemDeadline(item)
+		if err != nil {
+			return err
+		}
+		if deadline != 0 {
+			return txn.SetEntry(withDeadline(e, time.UnixMilli(deadline)))
+		}
+		e.ExpiresAt = item.ExpiresAt()
+		return txn.SetEntry(e)
+	})
+	return err
+}
+
+// GetJSONPath returns the field at the given dot-separated path in the JSON
+// document of the given key, or null if the document has no such field.
+func (c *Client) GetJSONPath(key string, path string) (_ interface{}, err error) {
+	var valCopy []byte
+	start := time.Now()
+	defer func() { c.track("get", key, len(valCopy), start, err) }()
+	fields, err := splitJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	var found bool
+	valCopy, found, err = c.readValue(key)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return c.missing(key)
+	}
+	doc, err := decodeJSON(key, valCopy)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range fields {
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			doc = v[field]
+		case []interface{}:
+			i, perr := strconv.Atoi(field)
+			if perr != nil || i < 0 || i >= len(v) {
+				return nil, nil
+			}
+			doc = v[i]
+		default:
+			return nil, nil
+		}
+	}
+	return doc, nil
+}
+
+// splitJSONPath returns the fields of the given dot-separated path.
+func splitJSONPath(path string) ([]string, error) {
+	fields := strings.Split(path, ".")
+	for _, field := range fields {
+		if field == "" {
+			return nil, newInvalidArgumentError("invalid JSON path %q", path)
+		}
+	}
+	return fields, nil
+}
+
+// setJSONPath sets the field at the given path in doc to value, and returns
+// the updated document.
+func setJSONPath(doc interface{}, fields []string, value interface{}) (interface{}, error) {
+	if len(fields) == 0 {
+		return value, nil
+	}
+	field := fields[0]
+	switch v := doc.(type) {
+	case nil:
+		child, err := setJSONPath(nil, fields[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{field: child}, nil
+	case map[string]interface{}:
+		child, err := setJSONPath(v[field], fields[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		v[field] = child
+		return v, nil
+	case []interface{}:
+		i, err := strconv.Atoi(field)
+		if err != nil || i < 0 || i > len(v) {
+			return nil, fmt.Errorf("invalid array index %q", field)
+		}
+		if i == len(v) {
+			v = append(v, nil)
+		}
+		child, err := setJSONPath(v[i], fields[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		v[i] = child
+		return v, nil
+	default:
+		return nil, fmt.Errorf("cannot set field %q of a value that is not an object", field)
+	}
+}
@@ -1,249 +1,1772 @@
-package kv
-
-import (
-	"fmt"
-	"time"
-
-	badger "github.com/dgraph-io/badger/v4"
-	"github.com/grafana/sobek"	
-	"go.k6.io/k6/js/modules"
-)
-
-type (
-	// KV is the global module instance that will create Client
-	// instances for each VU.
-	KV struct{}
-
-	// ModuleInstance represents an instance of the JS module.
-	ModuleInstance struct {
-		vu modules.VU
-		*Client
-	}
-)
-
-// Ensure the interfaces are implemented correctly
-var (
-	_ modules.Instance = &ModuleInstance{}
-	_ modules.Module   = &KV{}
-)
-
-type Client struct {
-	vu  modules.VU
-	db 	*badger.DB
-}
-
-var clients map[string]*Client
-
-func init() {
-	clients = make(map[string]*Client)
-	modules.Register("k6/x/kv", new(KV))	
-}
-
-// New returns a pointer to a new KV instance
-func New() *KV {
-	return &KV{}
-}
-
-// NewModuleInstance implements the modules.Module interface and returns
-// a new instance for each VU.
-func (*KV) NewModuleInstance(vu modules.VU) modules.Instance {
-	return &ModuleInstance{vu: vu, Client: &Client{vu: vu}}
-}
-
-// Exports implements the modules.Instance interface and returns
-// the exports of the JS module.
-func (mi *ModuleInstance) Exports() modules.Exports {
-	return modules.Exports{Named: map[string]interface{}{
-		"Client": mi.NewClient,
-	}}
-}
-
-// NewClient is the JS constructor for the Client
-// Arguments :
-// 	1 arg :  kv_name
-//	2 args : kv_name, filename
-// If filename="" then memory=true
-func (mi *ModuleInstance) NewClient(call sobek.ConstructorCall) *sobek.Object {	
-	rt := mi.vu.Runtime()
-
-	var name string = ""
-	var kvName string = ""
-	var memory bool = false
-
-	if len(call.Arguments) == 1 {
-		kvName = call.Arguments[0].String()	
-		//fmt.Printf("call.Arguments=%i, kvname=%s\n", call.Arguments, kvName)
-		memory=true	
-	}
-
-	if len(call.Arguments) == 2 {
-		kvName = call.Arguments[0].String()
-		name = call.Arguments[1].String()	
-		if name=="" {
-			memory=true
-		}
-		//fmt.Printf("call.Arguments=%i, kvname=%s, filename=%s, memory=%t\n", call.Arguments, kvName, name, memory)	
-	}
-	
-	if kvName==""{
-		kvName="default"
-	}
-
-	if client, exists:=clients[kvName];!exists {
-		var db *badger.DB
-		if memory {
-			db, _ = badger.Open(badger.DefaultOptions("").WithLoggingLevel(badger.ERROR).WithInMemory(true))
-			//fmt.Printf("Create badger in memory\n")	
-		} else {
-			db, _ = badger.Open(badger.DefaultOptions(name).WithLoggingLevel(badger.ERROR))
-			//fmt.Printf("Create badger in disk\n")	
-		}
-		client = &Client{vu:mi.vu,db: db}
-		clients[kvName]=client
-
-		return rt.ToValue(client).ToObject(rt)	
-	} else {
-		return rt.ToValue(client).ToObject(rt)
-	}
-}
-
-// Set the given key with the given value.
-func (c *Client) Set(key string, value string) error {
-	err := c.db.Update(func(txn *badger.Txn) error {
-		err := txn.Set([]byte(key), []byte(value))
-		return err
-	})
-	return err
-}
-
-// Set the given key with the given value with TTL in second
-func (c *Client) SetWithTTLInSecond(key string, value string, ttl int) error {
-	err := c.db.Update(func(txn *badger.Txn) error {
-		e := badger.NewEntry([]byte(key), []byte(value)).WithTTL((time.Duration(ttl) * time.Second))
-		err := txn.SetEntry(e)
-		return err
-	})
-	return err
-}
-
-// Get returns the value for the given key.
-func (c *Client) Get(key string) (string, error) {
-	var valCopy []byte
-	_ = c.db.View(func(txn *badger.Txn) error {
-		item, _ := txn.Get([]byte(key))
-		if item != nil {
-			valCopy, _ = item.ValueCopy(nil)
-		}
-		return nil
-	})
-	if len(valCopy) > 0 {
-		return string(valCopy), nil
-	}
-	return "", fmt.Errorf("error in get value with key %s", key)
-}
-
-// Pop returns the value for the given key and remove it
-func (c *Client) Pop(key string) (string, error) {
-	var valCopy []byte
-	_ = c.db.Update(func(txn *badger.Txn) error {
-		item, _ := txn.Get([]byte(key))
-		if item != nil {
-			valCopy, _ = item.ValueCopy(nil)
-			_ = txn.Delete([]byte(key))
-		}
-		return nil;
-	})
-	if len(valCopy) > 0 {
-		return string(valCopy), nil
-	}
-	return "", fmt.Errorf("error in get value with key %s", key)
-}
-
-
-func (c *Client) PopFirst() (string, error) {
-	var key []byte
-	_ = c.db.Update(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchSize = 10
-		it := txn.NewIterator(opts)
-		defer it.Close()
-		
-		for it.Rewind(); it.Valid(); it.Next() {
-		  item := it.Item()
-		  key := item.Key()
-		  _ = txn.Delete([]byte(key))
-		  fmt.Printf("First() - key=%s\n", key)	
-		  break
-		}
-		return nil
-	  })
-
-	fmt.Printf("First() - check len(key) > 0 key=%s\n", string(key))	
-	//fmt.Printf("First() - len key=%i\n", len(key))	
-
-	if len(key) > 0  {
-		fmt.Printf("First() - len(key) > 0 key=%s\n", key)	
-		return string(key), nil
-	}
-	return "", fmt.Errorf("First() - no data")
-}
-
-// Display the keys - values
-func (c *Client) Show() error {
-	err := c.db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchSize = 10
-		it := txn.NewIterator(opts)
-		defer it.Close()
-		for it.Rewind(); it.Valid(); it.Next() {
-		  item := it.Item()
-		  k := item.Key()
-		  err := item.Value(func(v []byte) error {
-			fmt.Printf("key=%s, value=%s\n", k, v)
-			return nil
-		  })
-		  if err != nil {
-			return err
-		  }
-		}
-		return nil
-	  })
-	return err
-}
-
-
-// ViewPrefix return all the key value pairs where the key starts with some prefix.
-func (c *Client) ViewPrefix(prefix string) map[string]string {
-	m := make(map[string]string)
-	c.db.View(func(txn *badger.Txn) error {
-		it := txn.NewIterator(badger.DefaultIteratorOptions)
-		defer it.Close()
-		prefix := []byte(prefix)
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			item := it.Item()
-			k := item.Key()
-			err := item.Value(func(v []byte) error {
-				m[string(k)] = string(v)
-				return nil
-			})
-			if err != nil {
-				return err
-			}
-		}
-		return nil
-	})
-	return m
-}
-
-// Delete the given key
-func (c *Client) Delete(key string) error {
-	err := c.db.Update(func(txn *badger.Txn) error {
-		item, _ := txn.Get([]byte(key))
-		if item != nil {
-			err := txn.Delete([]byte(key))
-			return err
-		}
-		return nil
-	})
-	return err
-}
+package kv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/grafana/sobek"
+	"github.com/sirupsen/logrus"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+)
+
+type (
+	// KV is the global module instance that will create Client
+	// instances for each VU.
+	KV struct{}
+
+	// ModuleInstance represents an instance of the JS module.
+	ModuleInstance struct {
+		vu      modules.VU
+		metrics *kvMetrics
+		env     map[string]string
+		*Client
+	}
+)
+
+// Ensure the interfaces are implemented correctly
+var (
+	_ modules.Instance = &ModuleInstance{}
+	_ modules.Module   = &KV{}
+)
+
+// Client is the JS object giving access to a named database. Each VU gets
+// its own Client, and all the clients opened with the same name share the
+// same store.
+type Client struct {
+	vu      modules.VU
+	metrics *kvMetrics
+	*store
+
+	// tags are added to the metrics emitted by the client.
+	tags map[string]string
+
+	// prefix and scope confine the keys of the client to a namespace, see
+	// namespace.
+	prefix string
+	scope  string
+
+	// slidingTTL is the TTL refreshed on every Get of a key that expires,
+	// 0 when sliding expiration is disabled.
+	slidingTTL time.Duration
+
+	// parent is the client a bucket was returned by, nil for the clients
+	// created by the constructor.
+	parent *Client
+
+	// env holds the environment variables of the test, which are only
+	// available in the init context.
+	env map[string]string
+
+	// closed is set once the client has been closed.
+	closed bool
+}
+
+// store is a named Badger database along with the settings it was opened
+// with, shared between VUs.
+type store struct {
+	name string
+	db   *badger.DB
+
+	// opts are the options the database was opened with.
+	opts options
+
+	// refs counts the clients using the store, it is guarded by the
+	// registry mutex.
+	refs int
+
+	// done is closed when the store is closed, to stop its background
+	// tasks.
+	done chan struct{}
+
+	// counters count the key operations, for the metrics endpoint.
+	counters *opCounters
+
+	// merges holds the merge strategies registered with RegisterMerge.
+	merges merges
+
+	// sequences holds the sequences used by NextSequence.
+	sequences sequences
+
+	// seqMu serializes the updates of the lists, the sorted sets and the
+	// other sequences.
+	seqMu sync.Mutex
+
+	// popMu serializes PopFirst, as concurrent pops of the same entry
+	// would conflict.
+	popMu sync.Mutex
+
+	// lockMu serializes the updates of the locks and the other
+	// coordination primitives.
+	lockMu sync.Mutex
+
+	// gcRuns and gcRewritten count the value log GC runs and the files they
+	// rewrote. They are accessed atomically.
+	gcRuns      uint64
+	gcRewritten uint64
+
+	// defaultTTL is applied to the entries written without an explicit TTL.
+	defaultTTL time.Duration
+
+	// throwOnMissing makes reads of missing keys throw instead of
+	// returning null.
+	throwOnMissing bool
+
+	// debug makes the clients log every key operation.
+	debug bool
+}
+
+// Entry is a key-value pair returned to scripts.
+type Entry struct {
+	Key   string      `js:"key"`
+	Value interface{} `js:"value"`
+
+	// size is the size of the stored value, for the metrics.
+	size int
+}
+
+var stores = newRegistry()
+
+func init() {
+	modules.Register("k6/x/kv", new(KV))
+}
+
+// New returns a pointer to a new KV instance
+func New() *KV {
+	return &KV{}
+}
+
+// NewModuleInstance implements the modules.Module interface and returns
+// a new instance for each VU.
+func (*KV) NewModuleInstance(vu modules.VU) modules.Instance {
+	m, err := registerMetrics(vu.InitEnv().Registry)
+	if err != nil {
+		common.Throw(vu.Runtime(), err)
+	}
+	env := vu.InitEnv().RuntimeOptions.Env
+	return &ModuleInstance{vu: vu, metrics: m, env: env, Client: &Client{vu: vu}}
+}
+
+// Exports implements the modules.Instance interface and returns
+// the exports of the JS module.
+func (mi *ModuleInstance) Exports() modules.Exports {
+	return modules.Exports{Named: map[string]interface{}{
+		"Client": mi.NewClient,
+	}}
+}
+
+// NewClient is the JS constructor for the Client. It accepts either an
+// options object or the legacy positional arguments, see parseOptions.
+// Clients sharing the same name share the same database.
+func (mi *ModuleInstance) NewClient(call sobek.ConstructorCall) *sobek.Object {
+	rt := mi.vu.Runtime()
+
+	opts, err := parseOptions(rt, call.Arguments)
+	if err == nil {
+		opts, err = opts.withDefaults(mi.lookupEnv)
+	}
+	if err != nil {
+		common.Throw(rt, wrapError(err))
+	}
+
+	client := &Client{vu: mi.vu, metrics: mi.metrics, env: mi.env}
+	s, err := stores.open(opts, mi.vu.Events().Global, client.logger())
+	if err != nil {
+		common.Throw(rt, wrapError(err))
+	}
+
+	client.store = s
+	client.tags = opts.tags
+	client.prefix = opts.prefix
+	client.scope = opts.scope
+	client.slidingTTL = opts.slidingTTL
+	if !s.opts.sameStore(opts) {
+		client.logger().Warnf("kv %q is already open, ignoring the options given to this client", opts.name)
+	}
+	return rt.ToValue(client).ToObject(rt)
+}
+
+// newStore returns the store of the given database opened with the given
+// options.
+func newStore(opts options, db *badger.DB) *store {
+	return &store{
+		name:       opts.name,
+		db:         db,
+		opts:       opts,
+		done:       make(chan struct{}),
+		counters:   newOpCounters(),
+		defaultTTL: opts.defaultTTL,
+
+		throwOnMissing: opts.throwOnMissing,
+		debug:          opts.debug,
+	}
+}
+
+// openDB opens the Badger database described by the given options.
+func openDB(opts options) (*badger.DB, error) {
+	if opts.inMemory {
+		db, err := badger.Open(opts.badgerOptions())
+		if err != nil {
+			return nil, fmt.Errorf("unable to open in-memory database %q: %w", opts.name, err)
+		}
+		return db, nil
+	}
+
+	if err := validatePath(opts.path, opts.readOnly); err != nil {
+		return nil, fmt.Errorf("unable to open database %q: %w", opts.name, err)
+	}
+	db, err := badger.Open(opts.badgerOptions())
+	if err != nil {
+		return nil, fmt.Errorf("unable to open database %q at %s: %w", opts.name, opts.path, err)
+	}
+	return db, nil
+}
+
+// validatePath checks that the given path is a writable directory, creating
+// it if it doesn't exist yet. In read-only mode, the directory must exist but
+// doesn't need to be writable.
+func validatePath(path string, readOnly bool) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) && !readOnly {
+		if err := os.MkdirAll(path, 0o750); err != nil {
+			return fmt.Errorf("cannot create directory %s: %w", path, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot access %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+	if readOnly {
+		return nil
+	}
+
+	f, err := os.CreateTemp(path, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("directory %s is not writable: %w", path, err)
+	}
+	_ = f.Close()
+	return os.Remove(f.Name())
+}
+
+// lookupEnv returns the value of the given environment variable as seen by
+// the test, which includes the variables passed with the --env flag.
+func (mi *ModuleInstance) lookupEnv(key string) (string, bool) {
+	if initEnv := mi.vu.InitEnv(); initEnv != nil && initEnv.LookupEnv != nil {
+		return initEnv.LookupEnv(key)
+	}
+	return os.LookupEnv(key)
+}
+
+// Set the given key with the given value, which is either a string, a
+// number, a boolean, an ArrayBuffer or a JSON-serializable object. The type
+// of the value is stored along with it, so that Get returns the same type.
+func (c *Client) Set(key string, value interface{}) (err error) {
+	var data []byte
+	start := time.Now()
+	defer func() { c.track("set", key, len(data), start, err) }()
+	data, valueType, err := encodeValue(value)
+	if err != nil {
+		return &Error{Name: InvalidArgumentError, Message: err.Error(), Key: key}
+	}
+	err = c.update(func(txn *badger.Txn) error {
+		err := txn.SetEntry(c.newEntry(c.key(key), data, valueType))
+		return err
+	})
+	return err
+}
+
+// Set the given key with the given value with TTL in second
+func (c *Client) SetWithTTLInSecond(key string, value string, ttl int) (err error) {
+	start := time.Now()
+	defer func() { c.track("set", key, len(value), start, err) }()
+	err = c.update(func(txn *badger.Txn) error {
+		e := withDeadline(badger.NewEntry(c.key(key), []byte(value)), time.Now().Add(time.Duration(ttl)*time.Second))
+		err := txn.SetEntry(e)
+		return err
+	})
+	return err
+}
+
+// SetWithTTL sets the given key with the given value and a TTL given either
+// as a number of milliseconds or as a duration string such as "1500ms".
+func (c *Client) SetWithTTL(key string, value string, ttl interface{}) (err error) {
+	start := time.Now()
+	defer func() { c.track("set", key, len(value), start, err) }()
+	d, err := toDuration(ttl, time.Millisecond)
+	if err != nil {
+		return err
+	}
+	if err := checkTTL(d, ttl); err != nil {
+		return err
+	}
+	return c.update(func(txn *badger.Txn) error {
+		return txn.SetEntry(withDeadline(badger.NewEntry(c.key(key), []byte(value)), time.Now().Add(d)))
+	})
+}
+
+// TTL returns the remaining lifetime in seconds of the given key, rounded
+// up, or -1 if the key does not expire.
+func (c *Client) TTL(key string) (_ int64, err error) {
+	start := time.Now()
+	defer func() { c.track("ttl", key, 0, start, err) }()
+	var expiresAt uint64
+	var deadline int64
+	err = c.view(func(txn *badger.Txn) error {
+		item, err := getItem(txn, c.key(key))
+		if err != nil {
+			return err
+		}
+		expiresAt = item.ExpiresAt()
+		deadline, err = itemDeadline(item)
+		return err
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, newKeyNotFoundError(key)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if deadline != 0 {
+		remaining := (deadline - time.Now().UnixMilli() + 999) / 1000
+		if remaining < 0 {
+			remaining = 0
+		}
+		return remaining, nil
+	}
+	if expiresAt == 0 {
+		return -1, nil
+	}
+	remaining := int64(expiresAt) - time.Now().Unix()
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, nil
+}
+
+// Expire sets the TTL of an existing key, keeping its value. The TTL is
+// either a number of seconds or a duration string such as "1m30s". It
+// returns false if the key does not exist.
+func (c *Client) Expire(key string, ttl interface{}) (_ bool, err error) {
+	start := time.Now()
+	defer func() { c.track("expire", key, 0, start, err) }()
+	d, err := toDuration(ttl, time.Second)
+	if err != nil {
+		return false, err
+	}
+	if err := checkTTL(d, ttl); err != nil {
+		return false, err
+	}
+	return c.rewriteWithTTL(key, d)
+}
+
+// Persist removes the TTL of the given key so that it never expires. It
+// returns false if the key does not exist.
+func (c *Client) Persist(key string) (_ bool, err error) {
+	start := time.Now()
+	defer func() { c.track("persist", key, 0, start, err) }()
+	return c.rewriteWithTTL(key, 0)
+}
+
+// Touch refreshes the TTL of the given key, given as a number of seconds or
+// a duration string, or to the sliding expiration of the client when ttl is
+// 0. It returns false if the key does not exist.
+func (c *Client) Touch(key string, ttl interface{}) (_ bool, err error) {
+	start := time.Now()
+	defer func() { c.track("touch", key, 0, start, err) }()
+	d, err := toDuration(ttl, time.Second)
+	if err != nil {
+		return false, err
+	}
+	if d == 0 {
+		d = c.slidingTTL
+	}
+	if err := checkTTL(d, ttl); err != nil {
+		return false, err
+	}
+	return c.rewriteWithTTL(key, d)
+}
+
+// SetSlidingExpiration enables sliding expiration on the client: every Get
+// of a key that has a TTL resets it to ttl, given as a number of seconds or
+// a duration string, so that keys read regularly stay alive while idle ones
+// expire. A ttl of 0 disables it. It only applies to this client, not to the
+// other clients sharing the database.
+func (c *Client) SetSlidingExpiration(ttl interface{}) error {
+	d, err := toDuration(ttl, time.Second)
+	if err != nil {
+		return err
+	}
+	if d != 0 {
+		if err := checkTTL(d, ttl); err != nil {
+			return err
+		}
+	}
+	c.slidingTTL = d
+	return nil
+}
+
+// Sync forces the database to flush its pending writes to disk. It is a
+// no-op for in-memory and read-only databases.
+func (c *Client) Sync() error {
+	if c.closed {
+		return c.closedError()
+	}
+	if c.opts.inMemory || c.opts.readOnly {
+		return nil
+	}
+	return wrapError(c.db.Sync())
+}
+
+// Close closes the client. The database is flushed and closed once all the
+// clients sharing it are closed, and in any case when the test exits.
+func (c *Client) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if c.parent != nil {
+		// Buckets share the store reference of the client they come from.
+		return nil
+	}
+	return stores.release(c.store)
+}
+
+// logger returns the logger of the VU the client belongs to.
+func (c *Client) logger() logrus.FieldLogger {
+	if state := c.vu.State(); state != nil {
+		return state.Logger
+	}
+	if initEnv := c.vu.InitEnv(); initEnv != nil {
+		return initEnv.Logger
+	}
+	return logrus.StandardLogger()
+}
+
+// track records a key operation in the kv_* metrics and in the counters of
+// the store and, when the client runs in debug mode, logs it at debug level
+// along with the size of the value involved and its latency.
+func (c *Client) track(op string, key string, size int, start time.Time, err error) {
+	c.counters.add(op, err)
+	c.pushMetrics(op, start, err)
+	if !c.debug {
+		return
+	}
+	l := c.logger().WithFields(logrus.Fields{
+		"kv":       c.name,
+		"op":       op,
+		"key":      key,
+		"size":     size,
+		"duration": time.Since(start),
+	})
+	if err != nil {
+		l.WithError(err).Debug("kv operation failed")
+		return
+	}
+	l.Debug("kv operation")
+}
+
+// update runs fn in a read-write transaction, converting the returned error
+// into an *Error. Transactions failing with a conflict are retried up to the
+// number of retries of the store, with an exponential backoff.
+func (c *Client) update(fn func(txn *badger.Txn) error) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+	err := c.db.Update(fn)
+	for attempt := 0; errors.Is(err, badger.ErrConflict) && attempt < c.opts.retries; attempt++ {
+		time.Sleep(c.opts.retryBackoff << attempt)
+		err = c.db.Update(fn)
+	}
+	return wrapError(err)
+}
+
+// view runs fn in a read-only transaction, converting the returned error
+// into an *Error.
+func (c *Client) view(fn func(txn *badger.Txn) error) error {
+	if c.closed {
+		return c.closedError()
+	}
+	return wrapError(c.db.View(fn))
+}
+
+// checkWritable returns an error if the client cannot write to the database,
+// either because it is closed or because the database is read-only.
+func (c *Client) checkWritable() error {
+	if c.closed {
+		return c.closedError()
+	}
+	if c.opts.readOnly {
+		return &Error{Name: ReadOnlyError, Message: fmt.Sprintf("kv %q is open in read-only mode", c.name)}
+	}
+	return nil
+}
+
+// closedError returns the error of an operation on a closed client.
+func (c *Client) closedError() error {
+	return &Error{Name: DatabaseClosedError, Message: fmt.Sprintf("client of kv %q is closed", c.name)}
+}
+
+// newEntry returns the entry to write for the given key and value of the
+// given type, expiring after the default TTL of the store if any.
+func (s *store) newEntry(key []byte, value []byte, valueType byte) *badger.Entry {
+	e := badger.NewEntry(key, value).WithMeta(valueType)
+	if s.defaultTTL > 0 {
+		e = withDeadline(e, time.Now().Add(s.defaultTTL))
+	}
+	return e
+}
+
+// metaDeadline flags, in the user metadata of an entry, a value prefixed
+// with the deadline of the entry, see withDeadline. The other bits hold the
+// type of the value.
+const metaDeadline byte = 0x80
+
+// withTTL returns the given entry expiring after the given TTL. Badger
+// stores expirations in whole seconds and truncates them, which can expire
+// an entry up to a second early, so the expiration is rounded up instead.
+// The internal keys relying on it check their own deadlines, such as the
+// leases, while the user entries use withDeadline.
+func withTTL(e *badger.Entry, ttl time.Duration) *badger.Entry {
+	e.ExpiresAt = uint64(time.Now().Add(ttl + time.Second - 1).Unix())
+	return e
+}
+
+// withDeadline returns the given entry expiring at the given deadline, with
+// a millisecond precision: its value is prefixed with the deadline, which
+// the readers check, while Badger only drops it at the next second.
+func withDeadline(e *badger.Entry, deadline time.Time) *badger.Entry {
+	prefixed := make([]byte, 8, 8+len(e.Value))
+	binary.BigEndian.PutUint64(prefixed, uint64(deadline.UnixMilli()))
+	e.Value = append(prefixed, e.Value...)
+	e.UserMeta |= metaDeadline
+	e.ExpiresAt = uint64(deadline.Add(time.Second - 1).Unix())
+	return e
+}
+
+// itemDeadline returns the deadline of the given item in Unix milliseconds,
+// or 0 if it has none.
+func itemDeadline(item *badger.Item) (int64, error) {
+	if item.UserMeta()&metaDeadline == 0 {
+		return 0, nil
+	}
+	var deadline int64
+	err := item.Value(func(val []byte) error {
+		if len(val) >= 8 {
+			deadline = int64(binary.BigEndian.Uint64(val))
+		}
+		return nil
+	})
+	return deadline, err
+}
+
+// expired reports whether the deadline of the given item has passed. An item
+// of which the deadline can't be read is reported as live, the error being
+// returned when reading its value.
+func expired(item *badger.Item) bool {
+	deadline, err := itemDeadline(item)
+	return err == nil && deadline != 0 && deadline <= time.Now().UnixMilli()
+}
+
+// getItem is txn.Get, also returning badger.ErrKeyNotFound for an entry of
+// which the deadline has passed.
+func getItem(txn *badger.Txn, key []byte) (*badger.Item, error) {
+	item, err := txn.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if expired(item) {
+		return nil, badger.ErrKeyNotFound
+	}
+	return item, nil
+}
+
+// itemValue returns a copy of the value of the given item, without its
+// deadline, along with its type.
+func itemValue(item *badger.Item) ([]byte, byte, error) {
+	valCopy, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if item.UserMeta()&metaDeadline != 0 && len(valCopy) >= 8 {
+		valCopy = valCopy[8:]
+	}
+	return valCopy, item.UserMeta() &^ metaDeadline, nil
+}
+
+// checkTTL returns an error if the given TTL, received from a script as ttl,
+// is shorter than a millisecond, the precision of the deadlines.
+func checkTTL(d time.Duration, ttl interface{}) error {
+	if d < time.Millisecond {
+		return newInvalidArgumentError("ttl must be at least one millisecond, got %v", ttl)
+	}
+	return nil
+}
+
+// rewriteWithTTL writes back the current value of the given key with a new
+// TTL, a zero TTL meaning no expiration. It returns false if the key does not
+// exist.
+func (c *Client) rewriteWithTTL(key string, ttl time.Duration) (bool, error) {
+	var found bool
+	err := c.update(func(txn *badger.Txn) error {
+		item, err := getItem(txn, c.key(key))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		valCopy, valueType, err := itemValue(item)
+		if err != nil {
+			return err
+		}
+		found = true
+		e := badger.NewEntry(c.key(key), valCopy).WithMeta(valueType)
+		if ttl > 0 {
+			e = withDeadline(e, time.Now().Add(ttl))
+		}
+		return txn.SetEntry(e)
+	})
+	if err != nil {
+		return false, err
+	}
+	return found, nil
+}
+
+// SetMany sets all the given key-value pairs, committing them together
+// through a single write batch. Values are stored with their type, as Set
+// does.
+func (c *Client) SetMany(entries map[string]interface{}) (err error) {
+	start := time.Now()
+	defer func() { c.track("setMany", "", 0, start, err) }()
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+	wb := c.db.NewWriteBatch()
+	defer wb.Cancel()
+	for key, value := range entries {
+		data, valueType, err := encodeValue(value)
+		if err != nil {
+			return &Error{Name: InvalidArgumentError, Message: err.Error(), Key: key}
+		}
+		if err := wb.SetEntry(c.newEntry(c.key(key), data, valueType)); err != nil {
+			return wrapError(err)
+		}
+	}
+	return wrapError(wb.Flush())
+}
+
+// Get returns the value for the given key, or null if the key does not
+// exist and the client wasn't created with throwOnMissing. The value has the
+// type it was given to Set with. The keys of the prefixes registered with
+// RegisterMerge return their merged value.
+// When sliding expiration is enabled, reading a key that expires refreshes
+// its TTL, unless the database is open in read-only mode.
+func (c *Client) Get(key string) (_ interface{}, err error) {
+	var valCopy []byte
+	start := time.Now()
+	defer func() { c.track("get", key, len(valCopy), start, err) }()
+	if c.closed {
+		return nil, c.closedError()
+	}
+	if op, _ := c.mergeOperator(c.namespace(), key); op != nil {
+		valCopy, err = op.Get()
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return c.missing(key)
+		}
+		if err != nil {
+			return nil, wrapError(err)
+		}
+		return string(valCopy), nil
+	}
+	var found, expiring bool
+	var meta byte
+	var version uint64
+	err = c.view(func(txn *badger.Txn) error {
+		item, err := getItem(txn, c.key(key))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+		expiring, version = item.ExpiresAt() != 0, item.Version()
+		valCopy, meta, err = itemValue(item)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return c.missing(key)
+	}
+	if c.slidingTTL > 0 && expiring && !c.opts.readOnly {
+		c.slide(key, version, valCopy, meta)
+	}
+	return c.decodeValue(meta, valCopy), nil
+}
+
+// slide refreshes the TTL of the given key, read by Get at the given version
+// with the given value, to the sliding expiration of the client. It runs
+// apart from the read so that reads never conflict, and gives up when the key
+// changed in the meantime, a conflict meaning that another client just wrote
+// or refreshed it.
+func (c *Client) slide(key string, version uint64, value []byte, valueType byte) {
+	err := c.db.Update(func(txn *badger.Txn) error {
+		item, err := getItem(txn, c.key(key))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil || item.Version() != version {
+			return err
+		}
+		e := badger.NewEntry(c.key(key), value).WithMeta(valueType)
+		return txn.SetEntry(withDeadline(e, time.Now().Add(c.slidingTTL)))
+	})
+	if err != nil && !errors.Is(err, badger.ErrConflict) {
+		c.logger().WithError(err).Warnf("unable to refresh the ttl of key %q", key)
+	}
+}
+
+// missing is the result of reading a key that does not exist: null, or an
+// error if the client was created with throwOnMissing.
+func (c *Client) missing(key string) (interface{}, error) {
+	if c.throwOnMissing {
+		return nil, newKeyNotFoundError(key)
+	}
+	return nil, nil
+}
+
+// readValue returns the value of the given key, and false if the key does
+// not exist.
+func (c *Client) readValue(key string) ([]byte, bool, error) {
+	var valCopy []byte
+	var found bool
+	err := c.view(func(txn *badger.Txn) error {
+		item, err := getItem(txn, c.key(key))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+		valCopy, _, err = itemValue(item)
+		return err
+	})
+	return valCopy, found, err
+}
+
+// GetMany returns the values for the given keys, read in a single
+// transaction. Missing keys are mapped to null.
+func (c *Client) GetMany(keys []string) (_ map[string]interface{}, err error) {
+	start := time.Now()
+	defer func() { c.track("getMany", "", 0, start, err) }()
+	m := make(map[string]interface{}, len(keys))
+	err = c.view(func(txn *badger.Txn) error {
+		for _, key := range keys {
+			item, err := getItem(txn, c.key(key))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				m[key] = nil
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			valCopy, valueType, err := itemValue(item)
+			if err != nil {
+				return err
+			}
+			m[key] = c.decodeValue(valueType, valCopy)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// GetOrDefault returns the value for the given key, with the type it was set
+// with, or defaultValue when the key does not exist.
+func (c *Client) GetOrDefault(key string, defaultValue interface{}) (_ interface{}, err error) {
+	var valCopy []byte
+	start := time.Now()
+	defer func() { c.track("get", key, len(valCopy), start, err) }()
+	var found bool
+	var meta byte
+	err = c.view(func(txn *badger.Txn) error {
+		item, err := getItem(txn, c.key(key))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+		valCopy, meta, err = itemValue(item)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return defaultValue, nil
+	}
+	return c.decodeValue(meta, valCopy), nil
+}
+
+// GetSet atomically sets the given key to newValue, stored with its type as
+// Set does, and returns the value it previously held, or null if the key did
+// not exist.
+func (c *Client) GetSet(key string, newValue interface{}) (_ interface{}, err error) {
+	var data []byte
+	start := time.Now()
+	defer func() { c.track("getSet", key, len(data), start, err) }()
+	data, valueType, err := encodeValue(newValue)
+	if err != nil {
+		return nil, &Error{Name: InvalidArgumentError, Message: err.Error(), Key: key}
+	}
+	var old []byte
+	var meta byte
+	err = c.update(func(txn *badger.Txn) error {
+		item, err := getItem(txn, c.key(key))
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+		if item != nil {
+			old, meta, err = itemValue(item)
+			if err != nil {
+				return err
+			}
+		}
+		return txn.SetEntry(c.newEntry(c.key(key), data, valueType))
+	})
+	if err != nil || old == nil {
+		return nil, err
+	}
+	return c.decodeValue(meta, old), nil
+}
+
+// SetIfNotExists sets the given key with the given value, stored with its
+// type as Set does, only if the key does not exist yet. It returns true if
+// the value was written.
+func (c *Client) SetIfNotExists(key string, value interface{}) (_ bool, err error) {
+	var data []byte
+	start := time.Now()
+	defer func() { c.track("setIfNotExists", key, len(data), start, err) }()
+	data, valueType, err := encodeValue(value)
+	if err != nil {
+		return false, &Error{Name: InvalidArgumentError, Message: err.Error(), Key: key}
+	}
+	var set bool
+	err = c.update(func(txn *badger.Txn) error {
+		_, err := getItem(txn, c.key(key))
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+		set = true
+		return txn.SetEntry(c.newEntry(c.key(key), data, valueType))
+	})
+	if err != nil {
+		return false, err
+	}
+	return set, nil
+}
+
+// CompareAndSwap sets the given key to newValue, stored with its type as Set
+// does, only if its current value is equal to expected, compared in their
+// stored form. It returns true if the value was swapped.
+func (c *Client) CompareAndSwap(key string, expected interface{}, newValue interface{}) (_ bool, err error) {
+	var data []byte
+	start := time.Now()
+	defer func() { c.track("compareAndSwap", key, len(data), start, err) }()
+	want, _, err := encodeValue(expected)
+	if err != nil {
+		return false, &Error{Name: InvalidArgumentError, Message: err.Error(), Key: key}
+	}
+	data, valueType, err := encodeValue(newValue)
+	if err != nil {
+		return false, &Error{Name: InvalidArgumentError, Message: err.Error(), Key: key}
+	}
+	var swapped bool
+	err = c.update(func(txn *badger.Txn) error {
+		item, err := getItem(txn, c.key(key))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		current, _, err := itemValue(item)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(current, want) {
+			return nil
+		}
+		swapped = true
+		return txn.SetEntry(c.newEntry(c.key(key), data, valueType))
+	})
+	if err != nil {
+		return false, err
+	}
+	return swapped, nil
+}
+
+// IncrByFloat atomically adds delta to the floating point number stored at
+// the given key and returns the new value. A missing key is treated as 0.
+// Values are always formatted with strconv, independently of the locale.
+func (c *Client) IncrByFloat(key string, delta float64) (_ float64, err error) {
+	start := time.Now()
+	defer func() { c.track("incrByFloat", key, 0, start, err) }()
+	var result float64
+	err = c.update(func(txn *badger.Txn) error {
+		var current float64
+		item, err := getItem(txn, c.key(key))
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+		if item != nil {
+			valCopy, _, err := itemValue(item)
+			if err != nil {
+				return err
+			}
+			current, err = strconv.ParseFloat(string(valCopy), 64)
+			if err != nil {
+				return &Error{
+					Name:    InvalidArgumentError,
+					Message: fmt.Sprintf("value of key %s is not a valid float", key),
+					Key:     key,
+					cause:   err,
+				}
+			}
+		}
+		result = current + delta
+		e := c.newEntry(c.key(key), []byte(strconv.FormatFloat(result, 'f', -1, 64)), valueTypeNumber)
+		return txn.SetEntry(e)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result, nil
+}
+
+// Append atomically appends suffix to the value of the given key, creating
+// the key if it does not exist, and returns the length of the new value.
+func (c *Client) Append(key string, suffix string) (_ int, err error) {
+	var length int
+	start := time.Now()
+	defer func() { c.track("append", key, length, start, err) }()
+	err = c.update(func(txn *badger.Txn) error {
+		var current []byte
+		item, err := getItem(txn, c.key(key))
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+		if item != nil {
+			current, _, err = itemValue(item)
+			if err != nil {
+				return err
+			}
+		}
+		value := append(current, suffix...)
+		length = len(value)
+		return txn.SetEntry(c.newEntry(c.key(key), value, valueTypeString))
+	})
+	if err != nil {
+		return 0, err
+	}
+	return length, nil
+}
+
+// Exists reports whether the given key is present, without copying its value.
+func (c *Client) Exists(key string) (_ bool, err error) {
+	start := time.Now()
+	defer func() { c.track("exists", key, 0, start, err) }()
+	var exists bool
+	err = c.view(func(txn *badger.Txn) error {
+		_, err := getItem(txn, c.key(key))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		exists = true
+		return nil
+	})
+	return exists, err
+}
+
+// Rename atomically moves the value of the given key to newKey, keeping its
+// type and TTL, and overwriting newKey if it exists. It returns false if the
+// key does not exist.
+func (c *Client) Rename(key string, newKey string) (bool, error) {
+	return c.copyKey("rename", key, newKey, true)
+}
+
+// Copy atomically copies the value of the given key to dstKey, keeping its
+// type and TTL, and overwriting dstKey if it exists. It returns false if the
+// key does not exist.
+func (c *Client) Copy(key string, dstKey string) (bool, error) {
+	return c.copyKey("copy", key, dstKey, false)
+}
+
+// copyKey copies the value of the given key to dstKey in a single
+// transaction, deleting the key when move is true. The operation is tracked
+// as op.
+func (c *Client) copyKey(op string, key string, dstKey string, move bool) (_ bool, err error) {
+	var valCopy []byte
+	start := time.Now()
+	defer func() { c.track(op, key, len(valCopy), start, err) }()
+	var found bool
+	err = c.update(func(txn *badger.Txn) error {
+		item, err := getItem(txn, c.key(key))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+		// The value is copied as is, along with its deadline if any.
+		if valCopy, err = item.ValueCopy(nil); err != nil {
+			return err
+		}
+		e := badger.NewEntry(c.key(dstKey), valCopy).WithMeta(item.UserMeta())
+		e.ExpiresAt = item.ExpiresAt()
+		if move && key != dstKey {
+			if err := txn.Delete(c.key(key)); err != nil {
+				return err
+			}
+		}
+		return txn.SetEntry(e)
+	})
+	if err != nil {
+		return false, err
+	}
+	return found, nil
+}
+
+// MovePrefix atomically moves the entries where the key starts with
+// srcPrefix to keys starting with dstPrefix instead, keeping the rest of
+// their keys, their types and their TTLs, and returns the number of entries
+// moved. At most limit entries are moved, in key order, a limit lower or
+// equal to 0 meaning all of them, as long as they fit in a single
+// transaction.
+func (c *Client) MovePrefix(srcPrefix string, dstPrefix string, limit int) (_ int, err error) {
+	start := time.Now()
+	defer func() { c.track("movePrefix", srcPrefix, 0, start, err) }()
+	if srcPrefix == dstPrefix {
+		return 0, newInvalidArgumentError("source and destination prefixes must differ")
+	}
+	var moved int
+	err = c.update(func(txn *badger.Txn) error {
+		src := c.key(srcPrefix)
+		var entries []*badger.Entry
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = src
+		it := txn.NewIterator(opts)
+		for it.Rewind(); it.Valid() && (limit <= 0 || len(entries) < limit); it.Next() {
+			item := it.Item()
+			if isInternalKey(item.Key()) || expired(item) {
+				continue
+			}
+			valCopy, err := item.ValueCopy(nil)
+			if err != nil {
+				it.Close()
+				return err
+			}
+			e := badger.NewEntry(item.KeyCopy(nil), valCopy).WithMeta(item.UserMeta())
+			e.ExpiresAt = item.ExpiresAt()
+			entries = append(entries, e)
+		}
+		it.Close()
+
+		// All the entries are deleted before any is written, as the new key
+		// of an entry may be the old key of another one.
+		for _, e := range entries {
+			if err := txn.Delete(e.Key); err != nil {
+				return err
+			}
+		}
+		for _, e := range entries {
+			e.Key = append(c.key(dstPrefix), e.Key[len(src):]...)
+			if err := txn.SetEntry(e); err != nil {
+				return err
+			}
+		}
+		moved = len(entries)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return moved, nil
+}
+
+// Pop returns the value for the given key and remove it, or null if the key
+// does not exist and the client wasn't created with throwOnMissing.
+func (c *Client) Pop(key string) (_ interface{}, err error) {
+	var valCopy []byte
+	start := time.Now()
+	defer func() { c.track("pop", key, len(valCopy), start, err) }()
+	var found bool
+	var meta byte
+	err = c.update(func(txn *badger.Txn) error {
+		item, err := getItem(txn, c.key(key))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+		valCopy, meta, err = itemValue(item)
+		if err != nil {
+			return err
+		}
+		return txn.Delete(c.key(key))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return c.missing(key)
+	}
+	return c.decodeValue(meta, valCopy), nil
+}
+
+// PopFirst removes the first key starting with the given prefix, in key
+// order, and returns it along with its value, or null if there is none.
+// Concurrent pops never return the same entry.
+func (c *Client) PopFirst(prefix string) (interface{}, error) {
+	return c.popEdge("popFirst", prefix, false)
+}
+
+// PopFirstWithPrefix is PopFirst, under a name making the prefix scope
+// explicit. Pop keeps removing a single given key.
+func (c *Client) PopFirstWithPrefix(prefix string) (interface{}, error) {
+	return c.popEdge("popFirst", prefix, false)
+}
+
+// PopLast removes the last key starting with the given prefix, in key order,
+// and returns it along with its value, or null if there is none.
+// Concurrent pops never return the same entry.
+func (c *Client) PopLast(prefix string) (interface{}, error) {
+	return c.popEdge("popLast", prefix, true)
+}
+
+// Peek returns the first key starting with the given prefix, in key order,
+// along with its value, or null if there is none, without removing it.
+func (c *Client) Peek(prefix string) (interface{}, error) {
+	return c.peekEdge("peek", prefix, false)
+}
+
+// Last returns the last key starting with the given prefix, in key order,
+// along with its value, or null if there is none, without removing it.
+func (c *Client) Last(prefix string) (interface{}, error) {
+	return c.peekEdge("last", prefix, true)
+}
+
+// peekEdge returns the first key starting with the given prefix, or the last
+// one when last is true, along with its value, or null if there is none.
+// The operation is tracked as op.
+func (c *Client) peekEdge(op string, prefix string, last bool) (_ interface{}, err error) {
+	var entry *Entry
+	start := time.Now()
+	defer func() { c.trackEntry(op, entry, start, err) }()
+	ns := c.namespace()
+	err = c.view(func(txn *badger.Txn) error {
+		var err error
+		entry, err = c.edgeEntry(txn, ns+prefix, last)
+		return err
+	})
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	entry.Key = entry.Key[len(ns):]
+	return entry, nil
+}
+
+// popEdge removes the first key starting with the given prefix, or the last
+// one when last is true, and returns it along with its value, or null if
+// there is none. The operation is tracked as op.
+func (c *Client) popEdge(op string, prefix string, last bool) (_ interface{}, err error) {
+	var entry *Entry
+	start := time.Now()
+	defer func() { c.trackEntry(op, entry, start, err) }()
+	ns := c.namespace()
+	c.popMu.Lock()
+	defer c.popMu.Unlock()
+	err = c.update(func(txn *badger.Txn) error {
+		var err error
+		entry, err = c.edgeEntry(txn, ns+prefix, last)
+		if err != nil || entry == nil {
+			return err
+		}
+		return txn.Delete([]byte(entry.Key))
+	})
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	entry.Key = entry.Key[len(ns):]
+	return entry, nil
+}
+
+// edgeEntry returns the first entry where the key starts with the given
+// prefix, or the last one when last is true, and nil if there is none.
+func (c *Client) edgeEntry(txn *badger.Txn, prefix string, last bool) (*Entry, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchSize = 10
+	opts.Prefix = []byte(prefix)
+	opts.Reverse = last
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Seek(iteratorSeek(opts.Prefix, last)); it.Valid(); it.Next() {
+		item := it.Item()
+		if isInternalKey(item.Key()) || expired(item) {
+			continue
+		}
+		return c.itemEntry(string(item.Key()), item)
+	}
+	return nil, nil
+}
+
+// itemEntry returns the entry with the given key of the given item, with
+// its value decoded according to its type.
+func (c *Client) itemEntry(key string, item *badger.Item) (*Entry, error) {
+	valCopy, valueType, err := itemValue(item)
+	if err != nil {
+		return nil, err
+	}
+	return &Entry{Key: key, Value: c.decodeValue(valueType, valCopy), size: len(valCopy)}, nil
+}
+
+// trackEntry tracks an operation returning the given entry, which is nil if
+// there was none.
+func (c *Client) trackEntry(op string, entry *Entry, start time.Time, err error) {
+	var key string
+	var size int
+	if entry != nil {
+		key, size = entry.Key, entry.size
+	}
+	c.track(op, key, size, start, err)
+}
+
+// Entries returns the key-value pairs where the key starts with the given
+// prefix, in key order, or in reverse key order with the Reverse option.
+// The second argument is either a limit, lower or equal to 0 meaning no
+// limit, or PageOptions, in which case a single page is returned as an
+// *EntriesPage.
+func (c *Client) Entries(prefix string, limit sobek.Value, opts ScanOptions) (_ interface{}, err error) {
+	start := time.Now()
+	defer func() { c.track("entries", prefix, 0, start, err) }()
+	page, paged, err := c.pageOptions(limit)
+	if err != nil {
+		return nil, err
+	}
+	if paged {
+		page.Reverse = page.Reverse || opts.Reverse
+		return c.entriesPage(prefix, page)
+	}
+	return c.scanEntries(prefix, nil, page.Limit, opts.Reverse)
+}
+
+// PageOptions holds the settings of the pages of Entries and ViewPrefix.
+type PageOptions struct {
+	// Limit is the maximum number of entries of the page, 0 meaning no limit.
+	Limit int `js:"limit"`
+	// Cursor is the NextCursor of the previous page, empty for the first one.
+	Cursor string `js:"cursor"`
+	// Reverse walks the entries in reverse key order.
+	Reverse bool `js:"reverse"`
+}
+
+// EntriesPage is a page of entries returned by Entries and ViewPrefix.
+type EntriesPage struct {
+	Entries []Entry `js:"entries"`
+	// NextCursor is the cursor of the next page, empty after the last one.
+	NextCursor string `js:"nextCursor"`
+}
+
+// pageOptions reads the optional argument of Entries and ViewPrefix that is
+// either a limit or PageOptions, and reports whether it is the latter.
+func (c *Client) pageOptions(v sobek.Value) (PageOptions, bool, error) {
+	var opts PageOptions
+	if common.IsNullish(v) {
+		return opts, false, nil
+	}
+	if _, isObject := v.(*sobek.Object); !isObject {
+		opts.Limit = int(v.ToInteger())
+		return opts, false, nil
+	}
+	if err := c.vu.Runtime().ExportTo(v, &opts); err != nil {
+		return opts, false, newInvalidArgumentError("invalid page options: %s", err)
+	}
+	return opts, true, nil
+}
+
+// entriesPage returns a page of the key-value pairs where the key starts
+// with the given prefix, along with the cursor of the next page, so that
+// large prefixes can be walked without loading them at once.
+func (c *Client) entriesPage(prefix string, opts PageOptions) (*EntriesPage, error) {
+	entries, more, err := c.scanPage(prefix, opts.Cursor, nil, opts.Limit, opts.Reverse)
+	if err != nil {
+		return nil, err
+	}
+	page := &EntriesPage{Entries: entries}
+	if more {
+		page.NextCursor = entries[len(entries)-1].Key
+	}
+	return page, nil
+}
+
+// Display the keys - values
+//
+// Deprecated: Show only logs the entries at debug level; use Entries to get
+// the data back.
+func (c *Client) Show() (err error) {
+	start := time.Now()
+	defer func() { c.track("show", "", 0, start, err) }()
+	ns := c.namespace()
+	err = c.view(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchSize = 10
+		opts.Prefix = []byte(ns)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			if isInternalKey(it.Item().Key()) || expired(it.Item()) {
+				continue
+			}
+			item := it.Item()
+			k := item.Key()[len(ns):]
+			v, _, err := itemValue(item)
+			if err != nil {
+				return err
+			}
+			c.logger().WithFields(logrus.Fields{"key": string(k), "value": string(v)}).Debug("Show()")
+		}
+		return nil
+	})
+	return err
+}
+
+// ViewPrefix return all the key value pairs where the key starts with some prefix.
+// With PageOptions, it returns a single page of them as an *EntriesPage
+// instead, as Entries does.
+func (c *Client) ViewPrefix(prefix string, page sobek.Value) (_ interface{}, err error) {
+	start := time.Now()
+	defer func() { c.track("viewPrefix", prefix, 0, start, err) }()
+	if !common.IsNullish(page) {
+		opts, paged, err := c.pageOptions(page)
+		if err != nil {
+			return nil, err
+		}
+		if !paged {
+			return nil, newInvalidArgumentError("viewPrefix takes page options, got %v", page)
+		}
+		return c.entriesPage(prefix, opts)
+	}
+	m := make(map[string]interface{})
+	ns := c.namespace()
+	err = c.view(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(ns + prefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if isInternalKey(it.Item().Key()) || expired(it.Item()) {
+				continue
+			}
+			item := it.Item()
+			valCopy, valueType, err := itemValue(item)
+			if err != nil {
+				return err
+			}
+			m[string(item.Key()[len(ns):])] = c.decodeValue(valueType, valCopy)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Count returns the number of keys starting with the given prefix, or the
+// total number of keys when the prefix is empty. Values are not read.
+func (c *Client) Count(prefix string) (_ int, err error) {
+	start := time.Now()
+	defer func() { c.track("count", prefix, 0, start, err) }()
+	var count int
+	err = c.view(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = c.key(prefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			if isInternalKey(it.Item().Key()) || expired(it.Item()) {
+				continue
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Keys returns the keys starting with the given prefix, without reading
+// their values. A limit lower or equal to 0 means no limit.
+func (c *Client) Keys(prefix string, limit int) (_ []string, err error) {
+	start := time.Now()
+	defer func() { c.track("keys", prefix, 0, start, err) }()
+	keys := make([]string, 0)
+	ns := c.namespace()
+	err = c.view(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(ns + prefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			if isInternalKey(it.Item().Key()) || expired(it.Item()) {
+				continue
+			}
+			if limit > 0 && len(keys) >= limit {
+				break
+			}
+			keys = append(keys, string(it.Item().Key()[len(ns):]))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RandomKey returns a key picked uniformly at random among the keys starting
+// with the given prefix, or null if there is none. Values are not read.
+func (c *Client) RandomKey(prefix string) (_ interface{}, err error) {
+	start := time.Now()
+	defer func() { c.track("randomKey", prefix, 0, start, err) }()
+	var key []byte
+	ns := c.namespace()
+	err = c.view(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(ns + prefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		seen := 0
+		for it.Rewind(); it.Valid(); it.Next() {
+			if isInternalKey(it.Item().Key()) || expired(it.Item()) {
+				continue
+			}
+			seen++
+			if rand.Intn(seen) == 0 {
+				key = it.Item().KeyCopy(key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, nil
+	}
+	return string(key[len(ns):]), nil
+}
+
+// RandomKeyWeighted returns a key picked at random among the keys starting
+// with the given prefix, each with a probability proportional to its value,
+// the weight of the key, or null if there is none. Keys of which the value is
+// not a positive number are never picked.
+func (c *Client) RandomKeyWeighted(prefix string) (_ interface{}, err error) {
+	start := time.Now()
+	defer func() { c.track("randomKeyWeighted", prefix, 0, start, err) }()
+	var key []byte
+	ns := c.namespace()
+	err = c.view(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(ns + prefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		var total float64
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			if isInternalKey(item.Key()) || expired(item) {
+				continue
+			}
+			val, _, err := itemValue(item)
+			if err != nil {
+				return err
+			}
+			weight, _ := strconv.ParseFloat(string(val), 64)
+			if !(weight > 0) || math.IsInf(weight, 1) {
+				continue
+			}
+			total += weight
+			if rand.Float64()*total < weight {
+				key = item.KeyCopy(key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, nil
+	}
+	return string(key[len(ns):]), nil
+}
+
+// Sample returns n key-value pairs picked at random among the keys starting
+// with the given prefix, using reservoir sampling over a single scan.
+func (c *Client) Sample(prefix string, n int) (_ []Entry, err error) {
+	start := time.Now()
+	defer func() { c.track("sample", prefix, 0, start, err) }()
+	entries := make([]Entry, 0)
+	if n <= 0 {
+		return entries, nil
+	}
+	ns := c.namespace()
+	err = c.view(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(ns + prefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		reservoir := make([][]byte, 0, n)
+		seen := 0
+		for it.Rewind(); it.Valid(); it.Next() {
+			if isInternalKey(it.Item().Key()) || expired(it.Item()) {
+				continue
+			}
+			seen++
+			if len(reservoir) < n {
+				reservoir = append(reservoir, it.Item().KeyCopy(nil))
+				continue
+			}
+			if i := rand.Intn(seen); i < n {
+				reservoir[i] = it.Item().KeyCopy(nil)
+			}
+		}
+
+		for _, key := range reservoir {
+			item, err := txn.Get(key)
+			if err != nil {
+				return err
+			}
+			entry, err := c.itemEntry(string(key[len(ns):]), item)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, *entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Delete the given key
+func (c *Client) Delete(key string) (err error) {
+	start := time.Now()
+	defer func() { c.track("delete", key, 0, start, err) }()
+	err = c.update(func(txn *badger.Txn) error {
+		_, err := getItem(txn, c.key(key))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return txn.Delete(c.key(key))
+	})
+	return err
+}
+
+// CompareAndDelete deletes the given key only if its current value is equal
+// to expected. It returns true if the key was deleted.
+func (c *Client) CompareAndDelete(key string, expected string) (_ bool, err error) {
+	start := time.Now()
+	defer func() { c.track("compareAndDelete", key, 0, start, err) }()
+	var deleted bool
+	err = c.update(func(txn *badger.Txn) error {
+		item, err := getItem(txn, c.key(key))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		current, _, err := itemValue(item)
+		if err != nil {
+			return err
+		}
+		if string(current) != expected {
+			return nil
+		}
+		deleted = true
+		return txn.Delete(c.key(key))
+	})
+	if err != nil {
+		return false, err
+	}
+	return deleted, nil
+}
+
+// DeleteMany deletes the given keys through a single write batch and returns
+// how many distinct keys existed, counted in a read-only transaction first.
+func (c *Client) DeleteMany(keys []string) (_ int, err error) {
+	start := time.Now()
+	defer func() { c.track("deleteMany", "", 0, start, err) }()
+	if err := c.checkWritable(); err != nil {
+		return 0, err
+	}
+	var existing [][]byte
+	err = c.view(func(txn *badger.Txn) error {
+		seen := make(map[string]bool, len(keys))
+		for _, key := range keys {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			_, err := getItem(txn, c.key(key))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			existing = append(existing, c.key(key))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	wb := c.db.NewWriteBatch()
+	defer wb.Cancel()
+	for _, key := range existing {
+		if err := wb.Delete(key); err != nil {
+			return 0, wrapError(err)
+		}
+	}
+	if err := wb.Flush(); err != nil {
+		return 0, wrapError(err)
+	}
+	return len(existing), nil
+}
+
+// DeletePrefix deletes all the keys starting with the given prefix.
+func (c *Client) DeletePrefix(prefix string) (err error) {
+	start := time.Now()
+	defer func() { c.track("deletePrefix", prefix, 0, start, err) }()
+	if prefix == "" {
+		return newInvalidArgumentError("prefix must not be empty")
+	}
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+	return wrapError(c.db.DropPrefix(c.key(prefix)))
+}
+
+// Clear deletes all the data stored in the database, or only the keys and
+// the data structures of the namespace of the client when it has one.
+func (c *Client) Clear() (err error) {
+	start := time.Now()
+	defer func() { c.track("clear", "", 0, start, err) }()
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+	ns := c.namespace()
+	if ns == "" {
+		return wrapError(c.db.DropAll())
+	}
+	prefixes, err := c.internalPrefixes(ns)
+	if err != nil {
+		return err
+	}
+	return wrapError(c.db.DropPrefix(append(prefixes, []byte(ns))...))
+}
+
+// internalPrefixes returns the prefixes of the keys of the data structures
+// and the coordination primitives of which the name starts with the given
+// namespace, one for each kind of them stored in the database.
+func (c *Client) internalPrefixes(ns string) ([][]byte, error) {
+	var prefixes [][]byte
+	seen := make(map[string]bool)
+	err := c.view(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(internalPrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			key := it.Item().Key()
+			i := bytes.IndexByte(key, ':')
+			if i < 0 || seen[string(key[:i])] {
+				continue
+			}
+			seen[string(key[:i])] = true
+			prefixes = append(prefixes, []byte(string(key[:i+1])+ns))
+		}
+		return nil
+	})
+	return prefixes, err
+}
+
+// toDuration converts a TTL received from a script into a time.Duration.
+// Strings are parsed as Go durations ("500ms", "2m30s") while numbers are
+// expressed in the given unit.
+func toDuration(v interface{}, unit time.Duration) (time.Duration, error) {
+	switch t := v.(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return time.Duration(t) * unit, nil
+	case int64:
+		return time.Duration(t) * unit, nil
+	case float64:
+		return time.Duration(t * float64(unit)), nil
+	case string:
+		d, err := time.ParseDuration(t)
+		if err != nil {
+			return 0, newInvalidArgumentError("invalid ttl %q: %s", t, err)
+		}
+		return d, nil
+	default:
+		return 0, newInvalidArgumentError("invalid ttl %v: expected a number or a duration string", v)
+	}
+}
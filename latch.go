@@ -0,0 +1,78 @@
+package kv
+
+import (
+	"errors"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/grafana/sobek"
+)
+
+// latchKey returns the key holding the remaining count of the given latch.
+func latchKey(name string) []byte {
+	return internalKey("latch", name)
+}
+
+// LatchInit sets the count of the given latch, which LatchWait waits to
+// reach 0.
+func (c *Client) LatchInit(name string, count int64) (err error) {
+	start := time.Now()
+	defer func() { c.track("latchInit", name, 0, start, err) }()
+	if count < 0 {
+		return newInvalidArgumentError("latch count must not be negative, got %d", count)
+	}
+	err = c.updateLocked(func(txn *badger.Txn) error {
+		return writeCount(txn, latchKey(c.scoped(name)), count)
+	})
+	return err
+}
+
+// LatchCountDown decrements the count of the given latch, initialized with
+// LatchInit, and returns the remaining count. Once the count reaches 0, it
+// stays there.
+func (c *Client) LatchCountDown(name string) (_ int64, err error) {
+	start := time.Now()
+	defer func() { c.track("latchCountDown", name, 0, start, err) }()
+	key := latchKey(c.scoped(name))
+	var remaining int64
+	err = c.updateLocked(func(txn *badger.Txn) error {
+		if _, err := txn.Get(key); err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return &Error{Name: KeyNotFoundError, Message: "latch " + name + " is not initialized", Key: name}
+			}
+			return err
+		}
+		var err error
+		if remaining, err = readCount(txn, key); err != nil || remaining == 0 {
+			return err
+		}
+		remaining--
+		return writeCount(txn, key, remaining)
+	})
+	return remaining, err
+}
+
+// LatchWait returns a promise resolved once the count of the given latch
+// reaches 0, or rejected with a Timeout error if it doesn't within the given
+// timeout in milliseconds. A timeout lower or equal to 0 means waiting until
+// the end of the test. A latch that is not initialized yet is waited for.
+func (c *Client) LatchWait(name string, timeoutMs int64) *sobek.Promise {
+	key := latchKey(c.scoped(name))
+	return c.waitPromise("latchWait", name, key, timeoutMs, func() (bool, error) {
+		var open bool
+		err := c.view(func(txn *badger.Txn) error {
+			if _, err := txn.Get(key); err != nil {
+				if errors.Is(err, badger.ErrKeyNotFound) {
+					return nil
+				}
+				return err
+			}
+			remaining, err := readCount(txn, key)
+			open = remaining == 0
+			return err
+		})
+		return open, err
+	}, func() interface{} {
+		return true
+	})
+}
@@ -0,0 +1,42 @@
+package kv
+
+import (
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// leaderKey returns the key holding the lease of the leader of the given
+// election, held by its global VU ID, see encodeLease.
+func leaderKey(name string) []byte {
+	return internalKey("leader", name)
+}
+
+// ElectLeader returns true if the calling VU is the leader of the given
+// election, renewing its lease for the given number of milliseconds, and
+// false otherwise. A VU becomes the leader when there is none, including
+// when the lease of the previous leader expired.
+func (c *Client) ElectLeader(name string, ttlMs int64) (_ bool, err error) {
+	start := time.Now()
+	defer func() { c.track("electLeader", name, 0, start, err) }()
+	key := leaderKey(c.scoped(name))
+	if ttlMs <= 0 {
+		return false, newInvalidArgumentError("leader ttl must be positive, got %d", ttlMs)
+	}
+	state := c.vu.State()
+	if state == nil {
+		return false, newInvalidArgumentError("electLeader can only be called from a VU")
+	}
+	id := int64(state.VUIDGlobal)
+
+	var leader bool
+	err = c.updateLocked(func(txn *badger.Txn) error {
+		held, err := leaseHolder(txn, key)
+		if err != nil || (held != 0 && held != id) {
+			return err
+		}
+		leader = true
+		return txn.SetEntry(leaseEntry(key, id, time.Duration(ttlMs)*time.Millisecond))
+	})
+	return leader, err
+}
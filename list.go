@@ -0,0 +1,315 @@
+package kv
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// internalPrefix starts the keys holding the lists and the other data
+// structures, which the key scans skip.
+const internalPrefix = "\x00"
+
+// isInternalKey reports whether the given key holds part of a data
+// structure.
+func isInternalKey(key []byte) bool {
+	return len(key) > 0 && key[0] == internalPrefix[0]
+}
+
+// internalKey returns the key of the data structure of the given kind and
+// name. The keys of its items start with this key followed by a 0 byte.
+func internalKey(kind string, name string) []byte {
+	return []byte(internalPrefix + kind + ":" + name)
+}
+
+// itemKey returns the key of the item of a data structure with the given
+// suffix.
+func itemKey(structKey []byte, suffix []byte) []byte {
+	k := make([]byte, 0, len(structKey)+1+len(suffix))
+	k = append(k, structKey...)
+	k = append(k, 0)
+	return append(k, suffix...)
+}
+
+// encodeIndex encodes the given index so that the byte order of the encoded
+// indexes matches their numeric order.
+func encodeIndex(i int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(i)^(1<<63))
+	return b
+}
+
+// decodeIndex decodes an index encoded by encodeIndex.
+func decodeIndex(b []byte) int64 {
+	return int64(binary.BigEndian.Uint64(b) ^ (1 << 63))
+}
+
+// seq is a double-ended sequence of values, such as a list, stored under
+// contiguous indexes from head (included) to tail (excluded). The bounds are
+// stored under the key of the sequence.
+type seq struct {
+	key        []byte
+	head, tail int64
+}
+
+// loadSeq reads the bounds of the sequence of the given kind and name.
+func loadSeq(txn *badger.Txn, kind string, name string) (*seq, error) {
+	s := &seq{key: internalKey(kind, name)}
+	item, err := txn.Get(s.key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = item.Value(func(val []byte) error {
+		if len(val) != 16 {
+			return errors.New("corrupted sequence bounds")
+		}
+		s.head = int64(binary.BigEndian.Uint64(val[:8]))
+		s.tail = int64(binary.BigEndian.Uint64(val[8:]))
+		return nil
+	})
+	return s, err
+}
+
+// len returns the number of values in the sequence.
+func (s *seq) len() int64 {
+	return s.tail - s.head
+}
+
+// save writes the bounds of the sequence, or deletes them once the sequence
+// is empty.
+func (s *seq) save(txn *badger.Txn) error {
+	if s.len() == 0 {
+		return txn.Delete(s.key)
+	}
+	val := make([]byte, 16)
+	binary.BigEndian.PutUint64(val[:8], uint64(s.head))
+	binary.BigEndian.PutUint64(val[8:], uint64(s.tail))
+	return txn.Set(s.key, val)
+}
+
+// push adds the given value, stored with the given type, at the front or at
+// the back of the sequence.
+func (s *seq) push(txn *badger.Txn, value []byte, valueType byte, front bool) error {
+	i := s.tail
+	if front {
+		s.head--
+		i = s.head
+	} else {
+		s.tail++
+	}
+	e := badger.NewEntry(itemKey(s.key, encodeIndex(i)), value).WithMeta(valueType)
+	if err := txn.SetEntry(e); err != nil {
+		return err
+	}
+	return s.save(txn)
+}
+
+// pop removes the value at the front or at the back of the sequence and
+// returns it along with its type. It returns a nil item if the sequence is
+// empty.
+func (s *seq) pop(txn *badger.Txn, front bool) (*badger.Item, []byte, error) {
+	if s.len() == 0 {
+		return nil, nil, nil
+	}
+	i := s.tail - 1
+	if front {
+		i = s.head
+		s.head++
+	} else {
+		s.tail--
+	}
+	k := itemKey(s.key, encodeIndex(i))
+	item, err := txn.Get(k)
+	if err != nil {
+		return nil, nil, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := txn.Delete(k); err != nil {
+		return nil, nil, err
+	}
+	return item, val, s.save(txn)
+}
+
+// updateSeq runs fn in a read-write transaction on the sequence of the given
+// kind and name. Sequence updates are serialized, as they all write the
+// bounds of their sequence and would conflict otherwise.
+func (c *Client) updateSeq(kind string, name string, fn func(txn *badger.Txn, s *seq) error) error {
+	c.seqMu.Lock()
+	defer c.seqMu.Unlock()
+	return c.update(func(txn *badger.Txn) error {
+		s, err := loadSeq(txn, kind, name)
+		if err != nil {
+			return err
+		}
+		return fn(txn, s)
+	})
+}
+
+// pushSeq adds the given value, of any type accepted by Set, at the front or
+// at the back of the sequence of the given kind and name, and returns the new
+// length of the sequence. The operation is tracked as op.
+func (c *Client) pushSeq(op string, kind string, name string, value interface{}, front bool) (_ int64, err error) {
+	var data []byte
+	start := time.Now()
+	defer func() { c.track(op, name, len(data), start, err) }()
+	data, valueType, err := encodeValue(value)
+	if err != nil {
+		return 0, &Error{Name: InvalidArgumentError, Message: err.Error(), Key: name}
+	}
+	var length int64
+	err = c.updateSeq(kind, name, func(txn *badger.Txn, s *seq) error {
+		if err := s.push(txn, data, valueType, front); err != nil {
+			return err
+		}
+		length = s.len()
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return length, nil
+}
+
+// popSeq removes the value at the front or at the back of the sequence of
+// the given kind and name and returns it, or null if the sequence is empty.
+// When not nil, prepare is called with the sequence in the same transaction
+// before the pop. The operation is tracked as op.
+func (c *Client) popSeq(
+	op string, kind string, name string, front bool, prepare func(txn *badger.Txn, s *seq) error,
+) (_ interface{}, err error) {
+	var valCopy []byte
+	start := time.Now()
+	defer func() { c.track(op, name, len(valCopy), start, err) }()
+	var valueType byte
+	var found bool
+	valCopy, valueType, found, err = c.popSeqValue(kind, name, front, prepare)
+	if err != nil || !found {
+		return nil, err
+	}
+	return c.decodeValue(valueType, valCopy), nil
+}
+
+// popSeqValue is popSeq returning the stored value along with its type, and
+// false if the sequence is empty. It does not use the JS runtime.
+func (c *Client) popSeqValue(
+	kind string, name string, front bool, prepare func(txn *badger.Txn, s *seq) error,
+) ([]byte, byte, bool, error) {
+	var valCopy []byte
+	var valueType byte
+	var found bool
+	err := c.updateSeq(kind, name, func(txn *badger.Txn, s *seq) error {
+		if prepare != nil {
+			if err := prepare(txn, s); err != nil {
+				return err
+			}
+		}
+		item, val, err := s.pop(txn, front)
+		if item != nil {
+			found, valueType, valCopy = true, item.UserMeta(), val
+		}
+		return err
+	})
+	return valCopy, valueType, found, err
+}
+
+// ListPush appends the given value, of any type accepted by Set, to the list
+// of the given key, and returns the new length of the list.
+func (c *Client) ListPush(key string, value interface{}) (int64, error) {
+	return c.pushSeq("listPush", "list", c.scoped(key), value, false)
+}
+
+// ListPop removes the last value of the list of the given key and returns
+// it, or null if the list is empty.
+func (c *Client) ListPop(key string) (interface{}, error) {
+	return c.popSeq("listPop", "list", c.scoped(key), false, nil)
+}
+
+// ListRange returns the values of the list of the given key from index start
+// to index stop, both included. Negative indexes count from the end of the
+// list, -1 being the last value.
+func (c *Client) ListRange(key string, start int64, stop int64) (_ []interface{}, err error) {
+	began := time.Now()
+	defer func() { c.track("listRange", key, 0, began, err) }()
+	return c.rangeSeq("list", c.scoped(key), start, stop)
+}
+
+// rangeSeq returns the values of the sequence of the given kind and name
+// from index start to index stop, both included, negative indexes counting
+// from the end.
+func (c *Client) rangeSeq(kind string, name string, start int64, stop int64) ([]interface{}, error) {
+	values := make([]interface{}, 0)
+	err := c.view(func(txn *badger.Txn) error {
+		s, err := loadSeq(txn, kind, name)
+		if err != nil {
+			return err
+		}
+		first, last := normalizeRange(start, stop, s.len())
+		if first > last {
+			return nil
+		}
+
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = itemKey(s.key, nil)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		n := last - first + 1
+		for it.Seek(itemKey(s.key, encodeIndex(s.head+first))); it.Valid() && n > 0; it.Next() {
+			item := it.Item()
+			valCopy, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			values = append(values, c.decodeValue(item.UserMeta(), valCopy))
+			n--
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// ListLen returns the length of the list of the given key, 0 if it does not
+// exist.
+func (c *Client) ListLen(key string) (_ int64, err error) {
+	start := time.Now()
+	defer func() { c.track("listLen", key, 0, start, err) }()
+	var length int64
+	err = c.view(func(txn *badger.Txn) error {
+		s, err := loadSeq(txn, "list", c.scoped(key))
+		if err != nil {
+			return err
+		}
+		length = s.len()
+		return nil
+	})
+	return length, err
+}
+
+// normalizeRange converts the given start and stop indexes, which are
+// negative when counting from the end, into positions in a sequence of the
+// given length.
+func normalizeRange(start, stop, length int64) (int64, int64) {
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+	return start, stop
+}
@@ -0,0 +1,278 @@
+package kv
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// lockKey returns the key holding the lease of the holder of the given lock,
+// see encodeLease.
+func lockKey(name string) []byte {
+	return internalKey("lock", name)
+}
+
+// lockTokenKey returns the key of the counter of the fencing tokens of the
+// given lock.
+func lockTokenKey(name string) []byte {
+	return internalKey("locktoken", name)
+}
+
+// updateLocked runs fn in a read-write transaction, serialized with the
+// other updates of locks and coordination primitives, which would
+// otherwise conflict.
+func (c *Client) updateLocked(fn func(txn *badger.Txn) error) error {
+	c.lockMu.Lock()
+	defer c.lockMu.Unlock()
+	return c.update(fn)
+}
+
+// AcquireLock acquires the given lock for a lease of the given number of
+// milliseconds, after which the lock is released automatically. It returns
+// the fencing token of the lease, which is greater than the tokens of all
+// the previous leases of the lock, or null if the lock is held.
+func (c *Client) AcquireLock(name string, ttlMs int64) (_ interface{}, err error) {
+	start := time.Now()
+	defer func() { c.track("acquireLock", name, 0, start, err) }()
+	if ttlMs <= 0 {
+		return nil, newInvalidArgumentError("lock ttl must be positive, got %d", ttlMs)
+	}
+	var token int64
+	err = c.updateLocked(func(txn *badger.Txn) error {
+		held, err := heldToken(txn, c.scoped(name))
+		if err != nil || held != 0 {
+			return err
+		}
+		if token, err = nextCounter(txn, lockTokenKey(c.scoped(name))); err != nil {
+			return err
+		}
+		return txn.SetEntry(leaseEntry(lockKey(c.scoped(name)), token, time.Duration(ttlMs)*time.Millisecond))
+	})
+	if err != nil || token == 0 {
+		return nil, err
+	}
+	return token, nil
+}
+
+// ReleaseLock releases the given lock if its lease still has the given
+// fencing token, and returns false otherwise, for instance if the lease
+// expired and the lock was acquired again.
+func (c *Client) ReleaseLock(name string, token int64) (_ bool, err error) {
+	start := time.Now()
+	defer func() { c.track("releaseLock", name, 0, start, err) }()
+	var released bool
+	err = c.updateLocked(func(txn *badger.Txn) error {
+		held, err := heldToken(txn, c.scoped(name))
+		if err != nil || held != token {
+			return err
+		}
+		released = true
+		return txn.Delete(lockKey(c.scoped(name)))
+	})
+	return released, err
+}
+
+// RenewLock extends the lease of the given lock with the given fencing token
+// to the given number of milliseconds from now. It returns false if the lease
+// was lost, because it expired or was released, in which case the critical
+// section must not go on.
+func (c *Client) RenewLock(name string, token int64, ttlMs int64) (_ bool, err error) {
+	start := time.Now()
+	defer func() { c.track("renewLock", name, 0, start, err) }()
+	if ttlMs <= 0 {
+		return false, newInvalidArgumentError("lock ttl must be positive, got %d", ttlMs)
+	}
+	var renewed bool
+	err = c.updateLocked(func(txn *badger.Txn) error {
+		held, err := heldToken(txn, c.scoped(name))
+		if err != nil || held != token {
+			return err
+		}
+		renewed = true
+		return txn.SetEntry(leaseEntry(lockKey(c.scoped(name)), token, time.Duration(ttlMs)*time.Millisecond))
+	})
+	return renewed, err
+}
+
+// heldToken returns the fencing token of the current lease of the given lock,
+// 0 if the lock is not held or its lease expired.
+func heldToken(txn *badger.Txn, name string) (int64, error) {
+	return leaseHolder(txn, lockKey(name))
+}
+
+// leaseHolder returns the holder of the lease stored at the given key, 0 if
+// there is none or it expired.
+func leaseHolder(txn *badger.Txn, key []byte) (int64, error) {
+	item, err := txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var holder int64
+	err = item.Value(func(val []byte) error {
+		holder = decodeLease(val, time.Now())
+		return nil
+	})
+	return holder, err
+}
+
+// leaseEntry returns the entry of the lease of the given holder, a fencing
+// token or a VU ID, for the given duration.
+func leaseEntry(key []byte, holder int64, ttl time.Duration) *badger.Entry {
+	lease := encodeLease(holder, time.Now().Add(ttl))
+	return withTTL(badger.NewEntry(key, lease), ttl)
+}
+
+// encodeLease encodes a lease of the given holder expiring at the given
+// time. The expiration is stored in milliseconds, as the key of the lease
+// only expires with the granularity of a second, see withTTL.
+func encodeLease(holder int64, until time.Time) []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b, uint64(holder))
+	binary.BigEndian.PutUint64(b[8:], uint64(until.UnixMilli()))
+	return b
+}
+
+// decodeLease returns the holder of the given lease, or 0 if the lease
+// expired at the given time. Leases encoded as a bare fencing token only
+// expire with their key.
+func decodeLease(b []byte, now time.Time) int64 {
+	if len(b) >= 16 && int64(binary.BigEndian.Uint64(b[8:])) <= now.UnixMilli() {
+		return 0
+	}
+	return decodeToken(b)
+}
+
+// encodeToken encodes a fencing token.
+func encodeToken(token int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(token))
+	return b
+}
+
+// decodeToken decodes a fencing token.
+func decodeToken(b []byte) int64 {
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+// rwLockWriterKey returns the key holding the fencing token of the writer of
+// the given read-write lock.
+func rwLockWriterKey(name string) []byte {
+	return itemKey(internalKey("rwlock", name), []byte{'w'})
+}
+
+// rwLockReadersPrefix returns the prefix of the keys of the readers of the
+// given read-write lock, each reader key ending with its fencing token.
+func rwLockReadersPrefix(name string) []byte {
+	return itemKey(internalKey("rwlock", name), []byte{'r'})
+}
+
+// AcquireReadLock acquires the given read-write lock for reading, for a
+// lease of the given number of milliseconds. Any number of readers can hold
+// the lock together, as long as no writer holds it. It returns the fencing
+// token of the lease, or null if a writer holds the lock.
+func (c *Client) AcquireReadLock(name string, ttlMs int64) (_ interface{}, err error) {
+	start := time.Now()
+	defer func() { c.track("acquireReadLock", name, 0, start, err) }()
+	return c.acquireRWLock(c.scoped(name), ttlMs, false)
+}
+
+// AcquireWriteLock acquires the given read-write lock for writing, for a
+// lease of the given number of milliseconds. It returns the fencing token of
+// the lease, or null if a reader or another writer holds the lock.
+func (c *Client) AcquireWriteLock(name string, ttlMs int64) (_ interface{}, err error) {
+	start := time.Now()
+	defer func() { c.track("acquireWriteLock", name, 0, start, err) }()
+	return c.acquireRWLock(c.scoped(name), ttlMs, true)
+}
+
+// acquireRWLock acquires the given read-write lock for reading or writing.
+func (c *Client) acquireRWLock(name string, ttlMs int64, write bool) (interface{}, error) {
+	if ttlMs <= 0 {
+		return nil, newInvalidArgumentError("lock ttl must be positive, got %d", ttlMs)
+	}
+	var token int64
+	err := c.updateLocked(func(txn *badger.Txn) error {
+		writer, err := leaseHolder(txn, rwLockWriterKey(name))
+		if err != nil || writer != 0 {
+			return err
+		}
+		if write {
+			readers, err := hasLeases(txn, rwLockReadersPrefix(name))
+			if err != nil || readers {
+				return err
+			}
+		}
+
+		if token, err = nextCounter(txn, internalKey("rwlocktoken", name)); err != nil {
+			return err
+		}
+		key := rwLockWriterKey(name)
+		if !write {
+			key = append(rwLockReadersPrefix(name), encodeToken(token)...)
+		}
+		return txn.SetEntry(leaseEntry(key, token, time.Duration(ttlMs)*time.Millisecond))
+	})
+	if err != nil || token == 0 {
+		return nil, err
+	}
+	return token, nil
+}
+
+// ReleaseReadLock releases the read lease of the given read-write lock with
+// the given fencing token, and returns false if the lease was already lost.
+func (c *Client) ReleaseReadLock(name string, token int64) (_ bool, err error) {
+	start := time.Now()
+	defer func() { c.track("releaseReadLock", name, 0, start, err) }()
+	return c.releaseRWLock(append(rwLockReadersPrefix(c.scoped(name)), encodeToken(token)...), token)
+}
+
+// ReleaseWriteLock releases the write lease of the given read-write lock with
+// the given fencing token, and returns false if the lease was already lost.
+func (c *Client) ReleaseWriteLock(name string, token int64) (_ bool, err error) {
+	start := time.Now()
+	defer func() { c.track("releaseWriteLock", name, 0, start, err) }()
+	return c.releaseRWLock(rwLockWriterKey(c.scoped(name)), token)
+}
+
+// releaseRWLock deletes the given lease key if it holds the given fencing
+// token.
+func (c *Client) releaseRWLock(key []byte, token int64) (bool, error) {
+	var released bool
+	err := c.updateLocked(func(txn *badger.Txn) error {
+		held, err := leaseHolder(txn, key)
+		if err != nil || held != token {
+			return err
+		}
+		released = true
+		return txn.Delete(key)
+	})
+	return released, err
+}
+
+// hasLeases reports whether a key starting with the given prefix holds a
+// lease that has not expired yet.
+func hasLeases(txn *badger.Txn, prefix []byte) (bool, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	now := time.Now()
+	for it.Rewind(); it.Valid(); it.Next() {
+		var holder int64
+		if err := it.Item().Value(func(val []byte) error {
+			holder = decodeLease(val, now)
+			return nil
+		}); err != nil {
+			return false, err
+		}
+		if holder != 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,173 @@
+package kv
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// mergeInterval is the interval at which the merge operators compact the
+// values added to their key.
+const mergeInterval = time.Second
+
+// mergeStrategies are the merge functions available to RegisterMerge, by
+// name.
+var mergeStrategies = map[string]badger.MergeFunc{
+	"sum":       mergeSum,
+	"max":       mergeMax,
+	"append":    mergeAppend,
+	"set-union": mergeSetUnion,
+}
+
+// merges holds the merge strategies registered on a store, by key prefix,
+// and the merge operators of the keys merged so far. It is safe for
+// concurrent use.
+type merges struct {
+	mu         sync.Mutex
+	strategies map[string]string
+	operators  map[string]*badger.MergeOperator
+}
+
+// RegisterMerge makes the keys starting with the given prefix merge the
+// values given to Merge with the given strategy, one of "sum", "max",
+// "append" and "set-union". Get returns the merged value of these keys.
+// When several prefixes match a key, the longest one wins. The prefix
+// applies to the keys of all the namespaces.
+func (c *Client) RegisterMerge(prefix string, strategy string) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+	if _, ok := mergeStrategies[strategy]; !ok {
+		return newInvalidArgumentError("unknown merge strategy %q", strategy)
+	}
+
+	c.merges.mu.Lock()
+	defer c.merges.mu.Unlock()
+	if current, ok := c.merges.strategies[prefix]; ok && current != strategy {
+		return newInvalidArgumentError("prefix %q already merges with %q", prefix, current)
+	}
+	if c.merges.strategies == nil {
+		c.merges.strategies = make(map[string]string)
+		c.merges.operators = make(map[string]*badger.MergeOperator)
+	}
+	c.merges.strategies[prefix] = strategy
+	return nil
+}
+
+// Merge merges the given value into the given key, with the strategy
+// registered for its prefix:
+//   - sum adds the number to the current value,
+//   - max keeps the greatest number,
+//   - append appends the value to the current one,
+//   - set-union adds the value to a JSON array of distinct values.
+func (c *Client) Merge(key string, value string) (err error) {
+	start := time.Now()
+	defer func() { c.track("merge", key, len(value), start, err) }()
+	if err = c.checkWritable(); err != nil {
+		return err
+	}
+
+	op, strategy := c.mergeOperator(c.namespace(), key)
+	if op == nil {
+		return newInvalidArgumentError("no merge strategy is registered for key %q", key)
+	}
+	val := []byte(value)
+	switch strategy {
+	case "sum", "max":
+		if _, perr := strconv.ParseFloat(value, 64); perr != nil {
+			return newInvalidArgumentError("merge %s of key %q requires a number, got %q", strategy, key, value)
+		}
+	case "set-union":
+		val, _ = json.Marshal([]string{value})
+	}
+	return wrapError(op.Add(val))
+}
+
+// mergeOperator returns the merge operator of the given key of the given
+// namespace along with its strategy, starting it if needed, or nil if no
+// strategy is registered for the key.
+func (s *store) mergeOperator(ns string, key string) (*badger.MergeOperator, string) {
+	s.merges.mu.Lock()
+	defer s.merges.mu.Unlock()
+
+	var prefix, strategy string
+	for p, st := range s.merges.strategies {
+		if strings.HasPrefix(key, p) && (strategy == "" || len(p) > len(prefix)) {
+			prefix, strategy = p, st
+		}
+	}
+	if strategy == "" {
+		return nil, ""
+	}
+	op, ok := s.merges.operators[ns+key]
+	if !ok {
+		op = s.db.GetMergeOperator([]byte(ns+key), mergeStrategies[strategy], mergeInterval)
+		s.merges.operators[ns+key] = op
+	}
+	return op, strategy
+}
+
+// stopMerges stops the merge operators of the store, writing their last
+// merged values.
+func (s *store) stopMerges() {
+	s.merges.mu.Lock()
+	defer s.merges.mu.Unlock()
+	for key, op := range s.merges.operators {
+		op.Stop()
+		delete(s.merges.operators, key)
+	}
+}
+
+// mergeSum returns the sum of both numbers.
+func mergeSum(existingVal, newVal []byte) []byte {
+	return formatMergeNumber(parseMergeNumber(existingVal) + parseMergeNumber(newVal))
+}
+
+// mergeMax returns the greatest of both numbers.
+func mergeMax(existingVal, newVal []byte) []byte {
+	a, b := parseMergeNumber(existingVal), parseMergeNumber(newVal)
+	if a > b {
+		return formatMergeNumber(a)
+	}
+	return formatMergeNumber(b)
+}
+
+// mergeAppend returns the new value appended to the existing one.
+func mergeAppend(existingVal, newVal []byte) []byte {
+	merged := make([]byte, 0, len(existingVal)+len(newVal))
+	return append(append(merged, existingVal...), newVal...)
+}
+
+// mergeSetUnion returns the JSON array of the distinct values of both JSON
+// arrays.
+func mergeSetUnion(existingVal, newVal []byte) []byte {
+	var a, b []string
+	_ = json.Unmarshal(existingVal, &a)
+	_ = json.Unmarshal(newVal, &b)
+	seen := make(map[string]bool, len(a)+len(b))
+	union := make([]string, 0, len(a)+len(b))
+	for _, v := range append(a, b...) {
+		if !seen[v] {
+			seen[v] = true
+			union = append(union, v)
+		}
+	}
+	merged, _ := json.Marshal(union)
+	return merged
+}
+
+// parseMergeNumber parses a number merged by sum or max, values that aren't
+// numbers counting as zero.
+func parseMergeNumber(v []byte) float64 {
+	f, _ := strconv.ParseFloat(string(v), 64)
+	return f
+}
+
+// formatMergeNumber formats a number merged by sum or max.
+func formatMergeNumber(f float64) []byte {
+	return []byte(strconv.FormatFloat(f, 'f', -1, 64))
+}
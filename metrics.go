@@ -0,0 +1,79 @@
+package kv
+
+import (
+	"time"
+
+	"go.k6.io/k6/metrics"
+)
+
+// kvMetrics are the custom k6 metrics emitted for the key operations.
+type kvMetrics struct {
+	// GetDuration is the latency of the get operations.
+	GetDuration *metrics.Metric
+
+	// SetDuration is the latency of the set operations.
+	SetDuration *metrics.Metric
+
+	// Ops counts all the key operations.
+	Ops *metrics.Metric
+
+	// Errors counts the key operations which failed.
+	Errors *metrics.Metric
+}
+
+// registerMetrics registers the custom metrics of the module.
+func registerMetrics(registry *metrics.Registry) (*kvMetrics, error) {
+	var (
+		m   kvMetrics
+		err error
+	)
+	if m.GetDuration, err = registry.NewMetric("kv_get_duration", metrics.Trend, metrics.Time); err != nil {
+		return nil, err
+	}
+	if m.SetDuration, err = registry.NewMetric("kv_set_duration", metrics.Trend, metrics.Time); err != nil {
+		return nil, err
+	}
+	if m.Ops, err = registry.NewMetric("kv_ops", metrics.Counter); err != nil {
+		return nil, err
+	}
+	if m.Errors, err = registry.NewMetric("kv_errors", metrics.Counter); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// pushMetrics emits the samples of a key operation, tagged with the name of
+// the kv, the operation and the tags of the client. Operations run outside
+// of a VU context, such as in the init context, are not measured.
+func (c *Client) pushMetrics(op string, start time.Time, err error) {
+	state := c.vu.State()
+	if state == nil || c.metrics == nil {
+		return
+	}
+
+	now := time.Now()
+	tags := state.Tags.GetCurrentValues().Tags.With("kv_name", c.name).With("op", op)
+	for k, v := range c.tags {
+		tags = tags.With(k, v)
+	}
+	sample := func(m *metrics.Metric, value float64) metrics.Sample {
+		return metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: m, Tags: tags},
+			Time:       now,
+			Value:      value,
+		}
+	}
+
+	samples := metrics.Samples{sample(c.metrics.Ops, 1)}
+	switch op {
+	case "get", "getInt", "getFloat", "getBool":
+		samples = append(samples, sample(c.metrics.GetDuration, metrics.D(now.Sub(start))))
+	case "set":
+		samples = append(samples, sample(c.metrics.SetDuration, metrics.D(now.Sub(start))))
+	}
+	if err != nil {
+		samples = append(samples, sample(c.metrics.Errors, 1))
+	}
+
+	metrics.PushIfNotDone(c.vu.Context(), state.Samples, samples)
+}
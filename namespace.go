@@ -0,0 +1,64 @@
+package kv
+
+import (
+	"strconv"
+
+	"go.k6.io/k6/lib"
+)
+
+// Client scopes, confining the keys of a client to a namespace.
+const (
+	scopeGlobal   = ""
+	scopeVU       = "vu"
+	scopeScenario = "scenario"
+)
+
+// namespace returns the prefix of the database keys of the client: its
+// prefix option followed by the prefix of its scope. Outside of a VU, in the
+// init context, or outside of a scenario, in setup and teardown, keys are not
+// prefixed by the scope.
+func (c *Client) namespace() string {
+	switch c.scope {
+	case scopeVU:
+		if state := c.vu.State(); state != nil {
+			return c.prefix + "vu:" + strconv.FormatUint(state.VUIDGlobal, 10) + ":"
+		}
+	case scopeScenario:
+		if ctx := c.vu.Context(); ctx != nil {
+			if scenario := lib.GetScenarioState(ctx); scenario != nil {
+				return c.prefix + "scenario:" + scenario.Name + ":"
+			}
+		}
+	}
+	return c.prefix
+}
+
+// scoped returns the given key or name of a data structure confined to the
+// namespace of the client.
+func (c *Client) scoped(key string) string {
+	return c.namespace() + key
+}
+
+// key returns the database key of the given key.
+func (c *Client) key(key string) []byte {
+	return []byte(c.scoped(key))
+}
+
+// Bucket returns a client with the full API of this one, of which all the
+// keys are confined to the bucket with the given name, stored under the
+// prefix of this client with the "<name>:" prefix. The bucket keeps the
+// scope of this client, which comes after the prefix of the bucket. Closing
+// a bucket only closes the bucket, the database stays open until its client
+// is closed.
+func (c *Client) Bucket(name string) (*Client, error) {
+	if name == "" {
+		return nil, newInvalidArgumentError("bucket name must not be empty")
+	}
+	if c.closed {
+		return nil, c.closedError()
+	}
+	b := *c
+	b.prefix = c.prefix + name + ":"
+	b.parent = c
+	return &b, nil
+}
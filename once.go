@@ -0,0 +1,132 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/grafana/sobek"
+)
+
+// The states of a once block, stored in the first byte of its key. A block
+// done with a result has it stored after the state.
+const (
+	onceRunning byte = 'r'
+	onceDone    byte = 'd'
+	onceResult  byte = 'v'
+)
+
+// onceKey returns the key holding the state of the given once block.
+func onceKey(name string) []byte {
+	return internalKey("once", name)
+}
+
+// TryOnce returns true to the first caller with the given name across the
+// whole test, and false to all the others.
+func (c *Client) TryOnce(name string) (_ bool, err error) {
+	start := time.Now()
+	defer func() { c.track("tryOnce", name, 0, start, err) }()
+	key := onceKey(c.scoped(name))
+	var first bool
+	err = c.updateLocked(func(txn *badger.Txn) error {
+		_, err := txn.Get(key)
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+		first = true
+		return txn.Set(key, []byte{onceDone})
+	})
+	return first, err
+}
+
+// Once calls fn for the first caller with the given name across the whole
+// test, and returns its result, stored for all the other callers, which wait
+// for fn to return if needed. If fn throws, the exception is rethrown and the
+// next caller calls fn again.
+func (c *Client) Once(name string, fn sobek.Value) (_ interface{}, err error) {
+	start := time.Now()
+	defer func() { c.track("once", name, 0, start, err) }()
+	key := onceKey(c.scoped(name))
+	call, ok := sobek.AssertFunction(fn)
+	if !ok {
+		return nil, newInvalidArgumentError("once requires a function")
+	}
+
+	ctx := c.vu.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var run bool
+	var state byte
+	var result []byte
+	var resultType byte
+	err = c.waitFor(ctx, key, func() (bool, error) {
+		var done bool
+		err := c.updateLocked(func(txn *badger.Txn) error {
+			item, err := txn.Get(key)
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				run = true
+				return txn.Set(key, []byte{onceRunning})
+			}
+			if err != nil {
+				return err
+			}
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			state = val[0]
+			if done = state != onceRunning; done {
+				result, resultType = val[1:], item.UserMeta()
+			}
+			return nil
+		})
+		return run || done, err
+	})
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	if !run {
+		if state != onceResult {
+			return nil, nil
+		}
+		return c.decodeValue(resultType, result), nil
+	}
+
+	// reset lets another VU run the block again after this one failed.
+	reset := func() {
+		if err := c.updateLocked(func(txn *badger.Txn) error {
+			return txn.Delete(key)
+		}); err != nil {
+			c.logger().WithError(err).Warnf("unable to reset once block %q", name)
+		}
+	}
+	v, callErr := call(sobek.Undefined())
+	if callErr != nil {
+		reset()
+		return nil, callErr
+	}
+
+	var exported interface{}
+	if v != nil && !sobek.IsUndefined(v) && !sobek.IsNull(v) {
+		exported = v.Export()
+	}
+	data := []byte{onceDone}
+	var valueType byte
+	if exported != nil {
+		encoded, t, err := encodeValue(exported)
+		if err != nil {
+			reset()
+			return nil, &Error{Name: InvalidArgumentError, Message: err.Error(), Key: name}
+		}
+		data, valueType = append([]byte{onceResult}, encoded...), t
+	}
+	err = c.updateLocked(func(txn *badger.Txn) error {
+		return txn.SetEntry(badger.NewEntry(key, data).WithMeta(valueType))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return exported, nil
+}
@@ -0,0 +1,308 @@
+package kv
+
+import (
+	"reflect"
+	"strconv"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/js/common"
+)
+
+// defaultRetryBackoff is the wait before the first retry of a conflicting
+// transaction when the retries option is set without retryBackoff.
+const defaultRetryBackoff = 10 * time.Millisecond
+
+// options holds the settings a Client is created with.
+type options struct {
+	name       string
+	path       string
+	inMemory   bool
+	readOnly   bool
+	syncWrites bool
+
+	// restoreFrom is the backup file loaded when the database is opened.
+	restoreFrom string
+
+	// refreshFrom is the file or HTTP URL re-imported every refreshInterval.
+	refreshFrom     string
+	refreshInterval time.Duration
+	refreshHeaders  map[string]string
+
+	defaultTTL time.Duration
+
+	// retries is the number of times a transaction failing with a
+	// conflict is retried, waiting retryBackoff before the first retry and
+	// twice as long before each of the next ones.
+	retries      int
+	retryBackoff time.Duration
+
+	// deadLetterAfter is the number of claims after which a nacked queue
+	// item is moved to the dead-letter queue, 0 meaning never.
+	deadLetterAfter int
+
+	// sequenceBandwidth is the number of IDs leased at once by each
+	// sequence.
+	sequenceBandwidth int
+
+	gcInterval     time.Duration
+	gcDiscardRatio float64
+
+	metricsPort int
+
+	throwOnMissing bool
+	debug          bool
+
+	// tags, prefix, scope and slidingTTL are specific to each client, while
+	// the other options are shared by all the clients of the store.
+	tags       map[string]string
+	prefix     string
+	scope      string
+	slidingTTL time.Duration
+}
+
+// parseOptions reads the Client constructor arguments, which are either an
+// options object:
+//
+//	new Client({name: "shared", path: "/tmp/kv", defaultTTL: "10m", prefix: "orders:"})
+//
+// or the legacy positional arguments (kv_name, filename, default_ttl).
+func parseOptions(rt *sobek.Runtime, args []sobek.Value) (options, error) {
+	if len(args) > 0 && !common.IsNullish(args[0]) {
+		if _, isString := args[0].Export().(string); !isString {
+			return parseOptionsObject(rt, args[0])
+		}
+	}
+	return parsePositionalOptions(args)
+}
+
+// parseOptionsObject reads the options object form of the constructor.
+func parseOptionsObject(rt *sobek.Runtime, v sobek.Value) (options, error) {
+	var raw struct {
+		Name       string `js:"name"`
+		Path       string `js:"path"`
+		InMemory   bool   `js:"inMemory"`
+		ReadOnly   bool   `js:"readOnly"`
+		SyncWrites bool   `js:"syncWrites"`
+
+		RestoreFrom string `js:"restoreFrom"`
+
+		RefreshFrom     string            `js:"refreshFrom"`
+		RefreshInterval interface{}       `js:"refreshInterval"`
+		RefreshHeaders  map[string]string `js:"refreshHeaders"`
+
+		DefaultTTL interface{} `js:"defaultTTL"`
+		SlidingTTL interface{} `js:"slidingTTL"`
+
+		Retries      int         `js:"retries"`
+		RetryBackoff interface{} `js:"retryBackoff"`
+
+		DeadLetterAfter int `js:"deadLetterAfter"`
+
+		SequenceBandwidth int `js:"sequenceBandwidth"`
+
+		GCInterval     interface{} `js:"gcInterval"`
+		GCDiscardRatio float64     `js:"gcDiscardRatio"`
+
+		MetricsPort int `js:"metricsPort"`
+
+		ThrowOnMissing bool `js:"throwOnMissing"`
+		Debug          bool `js:"debug"`
+
+		Tags   map[string]string `js:"tags"`
+		Prefix string            `js:"prefix"`
+		Scope  string            `js:"scope"`
+	}
+	if err := rt.ExportTo(v, &raw); err != nil {
+		return options{}, newInvalidArgumentError("invalid options: %s", err)
+	}
+
+	opts := options{
+		name:       raw.Name,
+		path:       raw.Path,
+		inMemory:   raw.InMemory,
+		readOnly:   raw.ReadOnly,
+		syncWrites: raw.SyncWrites,
+
+		restoreFrom: raw.RestoreFrom,
+
+		refreshFrom:    raw.RefreshFrom,
+		refreshHeaders: raw.RefreshHeaders,
+
+		retries: raw.Retries,
+
+		deadLetterAfter: raw.DeadLetterAfter,
+
+		sequenceBandwidth: raw.SequenceBandwidth,
+
+		gcDiscardRatio: raw.GCDiscardRatio,
+
+		metricsPort: raw.MetricsPort,
+
+		throwOnMissing: raw.ThrowOnMissing,
+		debug:          raw.Debug,
+
+		tags:   raw.Tags,
+		prefix: raw.Prefix,
+		scope:  raw.Scope,
+	}
+
+	var err error
+	if opts.defaultTTL, err = toDuration(raw.DefaultTTL, time.Second); err != nil {
+		return options{}, newInvalidArgumentError("invalid defaultTTL: %s", err)
+	}
+	if opts.slidingTTL, err = toDuration(raw.SlidingTTL, time.Second); err != nil {
+		return options{}, newInvalidArgumentError("invalid slidingTTL: %s", err)
+	}
+	if err := opts.checkTTLs(); err != nil {
+		return options{}, err
+	}
+	if opts.retryBackoff, err = toDuration(raw.RetryBackoff, time.Millisecond); err != nil {
+		return options{}, newInvalidArgumentError("invalid retryBackoff: %s", err)
+	}
+	if opts.retries < 0 || opts.retryBackoff < 0 {
+		return options{}, newInvalidArgumentError("retry options must not be negative")
+	}
+	if opts.retries > 0 && opts.retryBackoff == 0 {
+		opts.retryBackoff = defaultRetryBackoff
+	}
+	if opts.deadLetterAfter < 0 {
+		return options{}, newInvalidArgumentError("deadLetterAfter must not be negative")
+	}
+	if opts.sequenceBandwidth < 0 {
+		return options{}, newInvalidArgumentError("sequenceBandwidth must not be negative")
+	}
+	if opts.gcInterval, err = toDuration(raw.GCInterval, time.Second); err != nil {
+		return options{}, newInvalidArgumentError("invalid gcInterval: %s", err)
+	}
+	if opts.gcInterval < 0 {
+		return options{}, newInvalidArgumentError("gcInterval must not be negative")
+	}
+	if opts.refreshInterval, err = toDuration(raw.RefreshInterval, time.Second); err != nil {
+		return options{}, newInvalidArgumentError("invalid refreshInterval: %s", err)
+	}
+	if opts.refreshInterval < 0 {
+		return options{}, newInvalidArgumentError("refreshInterval must not be negative")
+	}
+	if (opts.refreshFrom == "") != (opts.refreshInterval == 0) {
+		return options{}, newInvalidArgumentError("refreshFrom and refreshInterval must be set together")
+	}
+	if opts.scope != scopeGlobal && opts.scope != scopeVU && opts.scope != scopeScenario {
+		return options{}, newInvalidArgumentError("unknown scope %q", opts.scope)
+	}
+	if opts.metricsPort < 0 || opts.metricsPort > 65535 {
+		return options{}, newInvalidArgumentError("invalid metricsPort %d", opts.metricsPort)
+	}
+	if opts.gcDiscardRatio < 0 || opts.gcDiscardRatio >= 1 {
+		return options{}, newInvalidArgumentError("gcDiscardRatio must be between 0 and 1, got %v", opts.gcDiscardRatio)
+	}
+
+	return opts, nil
+}
+
+// parsePositionalOptions reads the legacy positional form of the
+// constructor:
+//
+//	1 arg :  kv_name
+//	2 args : kv_name, filename
+//	3 args : kv_name, filename, default_ttl
+//
+// If filename="" then the database is kept in memory.
+func parsePositionalOptions(args []sobek.Value) (options, error) {
+	var opts options
+	if len(args) >= 1 {
+		opts.name = args[0].String()
+	}
+	if len(args) >= 2 {
+		opts.path = args[1].String()
+	}
+
+	if len(args) >= 3 {
+		ttl, err := toDuration(args[2].Export(), time.Second)
+		if err != nil {
+			return options{}, err
+		}
+		opts.defaultTTL = ttl
+		if err := opts.checkTTLs(); err != nil {
+			return options{}, err
+		}
+	}
+
+	return opts, nil
+}
+
+// checkTTLs returns an error if a TTL option is negative or, when set,
+// shorter than a millisecond, the precision of the deadlines.
+func (o options) checkTTLs() error {
+	if o.defaultTTL < 0 || o.slidingTTL < 0 {
+		return newInvalidArgumentError("ttl options must not be negative")
+	}
+	if (o.defaultTTL > 0 && o.defaultTTL < time.Millisecond) || (o.slidingTTL > 0 && o.slidingTTL < time.Millisecond) {
+		return newInvalidArgumentError("ttl options must be at least one millisecond")
+	}
+	return nil
+}
+
+// withDefaults fills in the settings left empty by the script, first from
+// the K6_KV_NAME, K6_KV_PATH and K6_KV_IN_MEMORY environment variables, then
+// with the built-in defaults. The database is kept in memory when no path is
+// configured.
+func (o options) withDefaults(lookupEnv func(string) (string, bool)) (options, error) {
+	if o.name == "" {
+		o.name, _ = lookupEnv("K6_KV_NAME")
+	}
+	if o.name == "" {
+		o.name = "default"
+	}
+
+	if o.path == "" {
+		o.path, _ = lookupEnv("K6_KV_PATH")
+	}
+
+	if v, ok := lookupEnv("K6_KV_IN_MEMORY"); ok && v != "" && !o.inMemory {
+		inMemory, err := strconv.ParseBool(v)
+		if err != nil {
+			return options{}, newInvalidArgumentError("invalid K6_KV_IN_MEMORY value %q: %s", v, err)
+		}
+		o.inMemory = inMemory
+	}
+	if o.path == "" {
+		o.inMemory = true
+	}
+	if o.sequenceBandwidth == 0 {
+		o.sequenceBandwidth = defaultSequenceBandwidth
+	}
+	if o.inMemory && o.readOnly {
+		return options{}, newInvalidArgumentError("readOnly requires a database path")
+	}
+	if o.readOnly && o.restoreFrom != "" {
+		return options{}, newInvalidArgumentError("restoreFrom cannot be used in readOnly mode")
+	}
+	if o.readOnly && o.refreshFrom != "" {
+		return options{}, newInvalidArgumentError("refreshFrom cannot be used in readOnly mode")
+	}
+
+	return o, nil
+}
+
+// sameStore reports whether both options open the same store the same way,
+// regardless of the options specific to each client.
+func (o options) sameStore(other options) bool {
+	o.tags, other.tags = nil, nil
+	o.prefix, other.prefix = "", ""
+	o.scope, other.scope = "", ""
+	o.slidingTTL, other.slidingTTL = 0, 0
+	return reflect.DeepEqual(o, other)
+}
+
+// badgerOptions returns the Badger options used to open the database.
+func (o options) badgerOptions() badger.Options {
+	if o.inMemory {
+		return badger.DefaultOptions("").WithLoggingLevel(badger.ERROR).WithInMemory(true)
+	}
+	return badger.DefaultOptions(o.path).
+		WithLoggingLevel(badger.ERROR).
+		WithReadOnly(o.readOnly).
+		WithSyncWrites(o.syncWrites)
+}
@@ -0,0 +1,201 @@
+package kv
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// Pool is a pool of test data, such as credentials, of which each item is
+// checked out by a single VU at a time.
+type Pool struct {
+	c    *Client
+	name string
+}
+
+// CheckedOutItem is an item checked out of a pool, which must be checked in
+// with its id.
+type CheckedOutItem struct {
+	ID    string      `js:"id"`
+	Value interface{} `js:"value"`
+}
+
+// Pool returns the pool with the given name, shared by all the clients of
+// the store.
+func (c *Client) Pool(name string) *Pool {
+	return &Pool{c: c, name: name}
+}
+
+// encodeCheckout returns the stored form of an item checked out until the
+// given time, the zero time meaning forever.
+func encodeCheckout(until time.Time, value []byte) []byte {
+	b := make([]byte, 8, 8+len(value))
+	if !until.IsZero() {
+		binary.BigEndian.PutUint64(b, uint64(until.UnixMilli()))
+	}
+	return append(b, value...)
+}
+
+// decodeCheckout decodes the stored form of a checked out item, and returns
+// whether its lease expired at the given time along with its value.
+func decodeCheckout(b []byte, now time.Time) (bool, []byte) {
+	until := int64(binary.BigEndian.Uint64(b[:8]))
+	return until != 0 && until <= now.UnixMilli(), b[8:]
+}
+
+// poolOutKey returns the key of the item checked out of the given pool with
+// the given sequence number.
+func poolOutKey(name string, n int64) []byte {
+	return itemKey(internalKey("poolout", name), encodeIndex(n))
+}
+
+// Load adds the given items, of any type accepted by Set, to the available
+// items of the pool, and returns the number of available items.
+func (p *Pool) Load(items []interface{}) (_ int64, err error) {
+	start := time.Now()
+	defer func() { p.c.track("poolLoad", p.name, 0, start, err) }()
+	type encoded struct {
+		data      []byte
+		valueType byte
+	}
+	values := make([]encoded, 0, len(items))
+	for _, item := range items {
+		data, valueType, err := encodeValue(item)
+		if err != nil {
+			return 0, &Error{Name: InvalidArgumentError, Message: err.Error(), Key: p.name}
+		}
+		values = append(values, encoded{data, valueType})
+	}
+	var available int64
+	name := p.c.scoped(p.name)
+	err = p.c.updateSeq("pool", name, func(txn *badger.Txn, s *seq) error {
+		for _, v := range values {
+			if err := s.push(txn, v.data, v.valueType, false); err != nil {
+				return err
+			}
+		}
+		available = s.len()
+		return nil
+	})
+	return available, err
+}
+
+// Checkout takes an available item out of the pool and returns it along with
+// the id of the checkout, or null if all the items are checked out. The item
+// stays checked out until it is checked in with this id or, when a lease is
+// given as a number of seconds or a duration string, until the lease
+// expires, in which case the item is returned to the available items
+// automatically.
+func (p *Pool) Checkout(lease interface{}) (_ interface{}, err error) {
+	var val []byte
+	start := time.Now()
+	defer func() { p.c.track("poolCheckout", p.name, len(val), start, err) }()
+	ttl, err := toDuration(lease, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if ttl < 0 {
+		return nil, newInvalidArgumentError("pool lease must not be negative")
+	}
+	var valueType byte
+	var n int64
+	name := p.c.scoped(p.name)
+	err = p.c.updateSeq("pool", name, func(txn *badger.Txn, s *seq) error {
+		now := time.Now()
+		if err := returnExpired(txn, name, s, now); err != nil {
+			return err
+		}
+		item, v, err := s.pop(txn, true)
+		if err != nil || item == nil {
+			return err
+		}
+		if n, err = nextCounter(txn, internalKey("poolouts", name)); err != nil {
+			return err
+		}
+		val, valueType = v, item.UserMeta()
+		var until time.Time
+		if ttl > 0 {
+			until = now.Add(ttl)
+		}
+		return txn.SetEntry(badger.NewEntry(poolOutKey(name, n), encodeCheckout(until, val)).WithMeta(valueType))
+	})
+	if err != nil || n == 0 {
+		return nil, err
+	}
+	return &CheckedOutItem{
+		ID:    p.name + "#" + strconv.FormatInt(n, 10),
+		Value: p.c.decodeValue(valueType, val),
+	}, nil
+}
+
+// Checkin returns the item of the checkout with the given id, as returned by
+// Checkout, to the available items of the pool. It returns false if the item
+// is not checked out anymore, for instance because its lease expired, even if
+// the same item was checked out again since.
+func (p *Pool) Checkin(id string) (_ bool, err error) {
+	start := time.Now()
+	defer func() { p.c.track("poolCheckin", p.name, 0, start, err) }()
+	pool, n, err := parseClaimID(id)
+	if err != nil {
+		return false, err
+	}
+	if pool != p.name {
+		return false, newInvalidArgumentError("checkout id %q is not of pool %q", id, p.name)
+	}
+	var checkedIn bool
+	name := p.c.scoped(p.name)
+	err = p.c.updateSeq("pool", name, func(txn *badger.Txn, s *seq) error {
+		if err := returnExpired(txn, name, s, time.Now()); err != nil {
+			return err
+		}
+		key := poolOutKey(name, n)
+		item, err := txn.Get(key)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if err := txn.Delete(key); err != nil {
+			return err
+		}
+		checkedIn = true
+		_, value := decodeCheckout(val, time.Time{})
+		return s.push(txn, value, item.UserMeta(), false)
+	})
+	return checkedIn, err
+}
+
+// returnExpired returns the items checked out of the given pool of which the
+// lease expired at the given time to its available items.
+func returnExpired(txn *badger.Txn, name string, s *seq, now time.Time) error {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = itemKey(internalKey("poolout", name), nil)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		item := it.Item()
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		expired, value := decodeCheckout(val, now)
+		if !expired {
+			continue
+		}
+		if err := txn.Delete(item.KeyCopy(nil)); err != nil {
+			return err
+		}
+		if err := s.push(txn, value, item.UserMeta(), false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package kv
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// opCounters counts the key operations run on a store, per operation.
+type opCounters struct {
+	mu     sync.Mutex
+	ops    map[string]uint64
+	errors map[string]uint64
+}
+
+func newOpCounters() *opCounters {
+	return &opCounters{ops: make(map[string]uint64), errors: make(map[string]uint64)}
+}
+
+// add counts an operation, and whether it failed.
+func (oc *opCounters) add(op string, err error) {
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+	oc.ops[op]++
+	if err != nil {
+		oc.errors[op]++
+	}
+}
+
+// snapshot returns a copy of the counters.
+func (oc *opCounters) snapshot() (ops, errors map[string]uint64) {
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+	ops = make(map[string]uint64, len(oc.ops))
+	for op, n := range oc.ops {
+		ops[op] = n
+	}
+	errors = make(map[string]uint64, len(oc.errors))
+	for op, n := range oc.errors {
+		errors[op] = n
+	}
+	return ops, errors
+}
+
+// serveMetrics exposes the counters and statistics of the store in the
+// Prometheus text format on the /metrics path of the given port, until the
+// store is closed.
+func (s *store) serveMetrics(port int) error {
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return fmt.Errorf("unable to serve the metrics of kv %q: %w", s.name, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() { _ = srv.Serve(ln) }()
+	go func() {
+		<-s.done
+		_ = srv.Close()
+	}()
+	return nil
+}
+
+// handleMetrics writes the metrics of the store in the Prometheus text
+// format.
+func (s *store) handleMetrics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	ops, errors := s.counters.snapshot()
+	writeOpMetric(w, "kv_ops_total", "Number of key operations.", s.name, ops)
+	writeOpMetric(w, "kv_errors_total", "Number of key operations which failed.", s.name, errors)
+
+	if s.db.IsClosed() {
+		return
+	}
+	stats := s.stats()
+	writeMetric(w, "kv_lsm_size_bytes", "gauge", "Size of the LSM tree.", s.name, float64(stats.LSMSize))
+	writeMetric(w, "kv_vlog_size_bytes", "gauge", "Size of the value log.", s.name, float64(stats.VLogSize))
+	writeMetric(w, "kv_keys", "gauge", "Estimated number of keys.", s.name, float64(stats.KeyCount))
+	writeMetric(w, "kv_pending_compactions", "gauge", "Number of LSM levels to compact.", s.name,
+		float64(stats.PendingCompactions))
+	writeMetric(w, "kv_gc_runs_total", "counter", "Number of value log GC runs.", s.name,
+		float64(atomic.LoadUint64(&s.gcRuns)))
+	writeMetric(w, "kv_gc_rewritten_files_total", "counter", "Number of value log files rewritten by the GC.", s.name,
+		float64(atomic.LoadUint64(&s.gcRewritten)))
+}
+
+// writeMetric writes a single sample metric.
+func writeMetric(w io.Writer, name, typ, help, kv string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	fmt.Fprintf(w, "%s{kv=%q} %v\n", name, kv, value)
+}
+
+// writeOpMetric writes a counter with a sample per operation.
+func writeOpMetric(w io.Writer, name, help, kv string, counts map[string]uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	ops := make([]string, 0, len(counts))
+	for op := range counts {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	for _, op := range ops {
+		fmt.Fprintf(w, "%s{kv=%q,op=%q} %d\n", name, kv, op, counts[op])
+	}
+}
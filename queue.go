@@ -0,0 +1,273 @@
+package kv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/grafana/sobek"
+)
+
+// Enqueue adds the given value, of any type accepted by Set, at the back of
+// the given queue, and returns the new length of the queue.
+func (c *Client) Enqueue(queue string, value interface{}) (int64, error) {
+	return c.pushSeq("enqueue", "queue", c.scoped(queue), value, false)
+}
+
+// Dequeue removes the value at the front of the given queue and returns it,
+// or null if the queue is empty. Each value is returned to a single caller,
+// whatever the number of VUs sharing the queue. The values scheduled with
+// EnqueueAt join the back of the queue once they are due.
+func (c *Client) Dequeue(queue string) (interface{}, error) {
+	name := c.scoped(queue)
+	return c.popSeq("dequeue", "queue", name, true, func(txn *badger.Txn, s *seq) error {
+		return promoteDueItems(txn, name, s, time.Now())
+	})
+}
+
+// WaitPop returns a promise resolved with the value dequeued from the front
+// of the given queue as soon as one is available, or rejected with a Timeout
+// error if none is within the given timeout in milliseconds. A timeout lower
+// or equal to 0 means waiting until the end of the test.
+func (c *Client) WaitPop(queue string, timeoutMs int64) *sobek.Promise {
+	var valCopy []byte
+	var valueType byte
+	name := c.scoped(queue)
+	return c.waitPromise("waitPop", queue, internalKey("queue", name), timeoutMs, func() (bool, error) {
+		var found bool
+		var err error
+		valCopy, valueType, found, err = c.popSeqValue("queue", name, true, func(txn *badger.Txn, s *seq) error {
+			return promoteDueItems(txn, name, s, time.Now())
+		})
+		return found, err
+	}, func() interface{} {
+		return c.decodeValue(valueType, valCopy)
+	})
+}
+
+// EnqueueAt schedules the given value, of any type accepted by Set, to join
+// the back of the given queue at the given time, either a Date or a number
+// of milliseconds since the Unix epoch. Until then, the value cannot be
+// dequeued.
+func (c *Client) EnqueueAt(queue string, value interface{}, at interface{}) (err error) {
+	var data []byte
+	start := time.Now()
+	defer func() { c.track("enqueueAt", queue, len(data), start, err) }()
+	due, err := toTime(at)
+	if err != nil {
+		return err
+	}
+	data, valueType, err := encodeValue(value)
+	if err != nil {
+		return &Error{Name: InvalidArgumentError, Message: err.Error(), Key: queue}
+	}
+
+	key := internalKey("delayed", c.scoped(queue))
+	c.seqMu.Lock()
+	defer c.seqMu.Unlock()
+	err = c.update(func(txn *badger.Txn) error {
+		n, err := nextCounter(txn, key)
+		if err != nil {
+			return err
+		}
+		suffix := append(encodeIndex(due.UnixMilli()), encodeIndex(n)...)
+		return txn.SetEntry(badger.NewEntry(itemKey(key, suffix), data).WithMeta(valueType))
+	})
+	return err
+}
+
+// promoteDueItems moves the values scheduled for the given queue that are
+// due at the given time to the back of the queue, in due time order.
+func promoteDueItems(txn *badger.Txn, queue string, s *seq, now time.Time) error {
+	prefix := itemKey(internalKey("delayed", queue), nil)
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	limit := encodeIndex(now.UnixMilli())
+	var due [][]byte
+	for it.Rewind(); it.Valid(); it.Next() {
+		item := it.Item()
+		if bytes.Compare(item.Key()[len(prefix):len(prefix)+8], limit) > 0 {
+			break
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if err := s.push(txn, val, item.UserMeta(), false); err != nil {
+			return err
+		}
+		due = append(due, item.KeyCopy(nil))
+	}
+	for _, k := range due {
+		if err := txn.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// toTime converts a Date or a number of milliseconds since the Unix epoch
+// into a time.Time.
+func toTime(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case int64:
+		return time.UnixMilli(t), nil
+	case float64:
+		return time.UnixMilli(int64(t)), nil
+	default:
+		return time.Time{}, newInvalidArgumentError("invalid time %v, expected a Date or a number of milliseconds", v)
+	}
+}
+
+// StackPush adds the given value, of any type accepted by Set, on top of the
+// given stack, and returns the new size of the stack.
+func (c *Client) StackPush(stack string, value interface{}) (int64, error) {
+	return c.pushSeq("stackPush", "stack", c.scoped(stack), value, false)
+}
+
+// StackPop removes the value on top of the given stack, the last one pushed,
+// and returns it, or null if the stack is empty.
+func (c *Client) StackPop(stack string) (interface{}, error) {
+	return c.popSeq("stackPop", "stack", c.scoped(stack), false, nil)
+}
+
+// PushFront adds the given value, of any type accepted by Set, at the front
+// of the given deque, and returns the new length of the deque.
+func (c *Client) PushFront(deque string, value interface{}) (int64, error) {
+	return c.pushSeq("pushFront", "deque", c.scoped(deque), value, true)
+}
+
+// PushBack adds the given value, of any type accepted by Set, at the back of
+// the given deque, and returns the new length of the deque.
+func (c *Client) PushBack(deque string, value interface{}) (int64, error) {
+	return c.pushSeq("pushBack", "deque", c.scoped(deque), value, false)
+}
+
+// PopFront removes the value at the front of the given deque and returns it,
+// or null if the deque is empty.
+func (c *Client) PopFront(deque string) (interface{}, error) {
+	return c.popSeq("popFront", "deque", c.scoped(deque), true, nil)
+}
+
+// PopBack removes the value at the back of the given deque and returns it,
+// or null if the deque is empty.
+func (c *Client) PopBack(deque string) (interface{}, error) {
+	return c.popSeq("popBack", "deque", c.scoped(deque), false, nil)
+}
+
+// nextCounter increments the counter stored under the given key and returns
+// its new value.
+func nextCounter(txn *badger.Txn, key []byte) (int64, error) {
+	n, err := readCount(txn, key)
+	if err != nil {
+		return 0, err
+	}
+	n++
+	return n, writeCount(txn, key, n)
+}
+
+// readCount returns the count stored under the given key, 0 if the key does
+// not exist.
+func readCount(txn *badger.Txn, key []byte) (int64, error) {
+	item, err := txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	err = item.Value(func(val []byte) error {
+		n = int64(binary.BigEndian.Uint64(val))
+		return nil
+	})
+	return n, err
+}
+
+// writeCount stores the given count under the given key.
+func writeCount(txn *badger.Txn, key []byte, n int64) error {
+	val := make([]byte, 8)
+	binary.BigEndian.PutUint64(val, uint64(n))
+	return txn.Set(key, val)
+}
+
+// popFirstItem removes the first item starting with the given prefix and
+// returns its value along with its type, and false if there is none.
+func popFirstItem(txn *badger.Txn, prefix []byte) ([]byte, byte, bool, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchSize = 1
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	it.Rewind()
+	if !it.Valid() {
+		return nil, 0, false, nil
+	}
+	item := it.Item()
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return val, item.UserMeta(), true, txn.Delete(item.KeyCopy(nil))
+}
+
+// PqPush adds the given value, of any type accepted by Set, to the given
+// priority queue with the given priority. Values with the same priority are
+// popped in insertion order.
+func (c *Client) PqPush(queue string, value interface{}, priority float64) (err error) {
+	var data []byte
+	start := time.Now()
+	defer func() { c.track("pqPush", queue, len(data), start, err) }()
+	if math.IsNaN(priority) {
+		return newInvalidArgumentError("priority must be a number")
+	}
+	data, valueType, err := encodeValue(value)
+	if err != nil {
+		return &Error{Name: InvalidArgumentError, Message: err.Error(), Key: queue}
+	}
+
+	key := internalKey("pq", c.scoped(queue))
+	c.seqMu.Lock()
+	defer c.seqMu.Unlock()
+	err = c.update(func(txn *badger.Txn) error {
+		n, err := nextCounter(txn, key)
+		if err != nil {
+			return err
+		}
+		// Higher priorities come first in key order.
+		suffix := append(encodeScore(-priority), encodeIndex(n)...)
+		return txn.SetEntry(badger.NewEntry(itemKey(key, suffix), data).WithMeta(valueType))
+	})
+	return err
+}
+
+// PqPop removes the value with the highest priority from the given priority
+// queue and returns it, or null if the queue is empty.
+func (c *Client) PqPop(queue string) (_ interface{}, err error) {
+	var valCopy []byte
+	start := time.Now()
+	defer func() { c.track("pqPop", queue, len(valCopy), start, err) }()
+	var valueType byte
+	var found bool
+
+	prefix := itemKey(internalKey("pq", c.scoped(queue)), nil)
+	c.seqMu.Lock()
+	defer c.seqMu.Unlock()
+	err = c.update(func(txn *badger.Txn) error {
+		var err error
+		valCopy, valueType, found, err = popFirstItem(txn, prefix)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return c.decodeValue(valueType, valCopy), nil
+}
@@ -0,0 +1,94 @@
+package kv
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/grafana/sobek"
+)
+
+// rateLimitKey returns the key holding the token bucket of the given rate
+// limiter.
+func rateLimitKey(name string) []byte {
+	return internalKey("ratelimit", name)
+}
+
+// RateLimit takes a token from the bucket of the given rate limiter, refilled
+// with the given number of tokens per second up to burst tokens, and returns
+// false if the bucket is empty, in which case the caller must not proceed.
+// The bucket is shared by all the VUs and starts full.
+func (c *Client) RateLimit(name string, ratePerSec float64, burst int64) (_ bool, err error) {
+	start := time.Now()
+	defer func() { c.track("rateLimit", name, 0, start, err) }()
+	if err = checkRateLimit(ratePerSec, burst); err != nil {
+		return false, err
+	}
+	return c.takeToken(rateLimitKey(c.scoped(name)), ratePerSec, burst)
+}
+
+// RateLimitWait returns a promise resolved once a token was taken from the
+// bucket of the given rate limiter, as RateLimit does, or rejected with a
+// Timeout error if none was within the given timeout in milliseconds.
+func (c *Client) RateLimitWait(name string, ratePerSec float64, burst int64, timeoutMs int64) *sobek.Promise {
+	key := rateLimitKey(c.scoped(name))
+	return c.waitPromise("rateLimitWait", name, key, timeoutMs, func() (bool, error) {
+		if err := checkRateLimit(ratePerSec, burst); err != nil {
+			return false, err
+		}
+		return c.takeToken(key, ratePerSec, burst)
+	}, func() interface{} {
+		return true
+	})
+}
+
+// checkRateLimit checks the settings of a rate limiter.
+func checkRateLimit(ratePerSec float64, burst int64) error {
+	if !(ratePerSec > 0) || math.IsInf(ratePerSec, 1) {
+		return newInvalidArgumentError("rate limit must be a positive number, got %v", ratePerSec)
+	}
+	if burst <= 0 {
+		return newInvalidArgumentError("rate limit burst must be positive, got %d", burst)
+	}
+	return nil
+}
+
+// takeToken refills the token bucket stored under the given key for the time
+// elapsed since its last update, and takes a token from it if there is one.
+func (c *Client) takeToken(key []byte, ratePerSec float64, burst int64) (bool, error) {
+	var taken bool
+	err := c.updateLocked(func(txn *badger.Txn) error {
+		now := time.Now()
+		tokens := float64(burst)
+		item, err := txn.Get(key)
+		switch {
+		case errors.Is(err, badger.ErrKeyNotFound):
+		case err != nil:
+			return err
+		default:
+			err := item.Value(func(val []byte) error {
+				last := time.UnixMilli(int64(binary.BigEndian.Uint64(val[8:16])))
+				tokens = math.Float64frombits(binary.BigEndian.Uint64(val[:8])) +
+					now.Sub(last).Seconds()*ratePerSec
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if tokens > float64(burst) {
+				tokens = float64(burst)
+			}
+		}
+		if tokens < 1 {
+			return nil
+		}
+		taken = true
+		b := make([]byte, 16)
+		binary.BigEndian.PutUint64(b[:8], math.Float64bits(tokens-1))
+		binary.BigEndian.PutUint64(b[8:], uint64(now.UnixMilli()))
+		return txn.Set(key, b)
+	})
+	return taken, err
+}
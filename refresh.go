@@ -0,0 +1,71 @@
+package kv
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// scheduleRefresh re-imports the JSON or JSONL entries of the given file or
+// HTTP URL every interval, until the store is closed.
+func (s *store) scheduleRefresh(source string, interval time.Duration, headers map[string]string, logger logrus.FieldLogger) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer cancel()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				if _, err := s.refresh(ctx, source, headers, logger); err != nil {
+					logger.WithError(err).Warnf("refresh of kv %q from %s failed", s.name, source)
+				}
+			}
+		}
+	}()
+	go func() {
+		select {
+		case <-s.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// refresh imports the entries of the given file or HTTP URL and returns
+// their number. Downloads are bounded by defaultImportTimeout, so that a
+// stalled server doesn't block the next refreshes.
+func (s *store) refresh(ctx context.Context, source string, headers map[string]string, logger logrus.FieldLogger) (int, error) {
+	var (
+		r   io.ReadCloser
+		err error
+	)
+	path := source
+	if u, perr := url.Parse(source); perr == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		path = u.Path
+		r, err = openURL(ctx, source, headers, defaultImportTimeout)
+	} else {
+		r, err = os.Open(source)
+		if err != nil {
+			err = fmt.Errorf("unable to open import file %s: %w", source, err)
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = r.Close() }()
+
+	format, err := importFormat(path, "")
+	if err != nil {
+		return 0, err
+	}
+	return s.importJSON(bufio.NewReader(r), format, "", source, logger)
+}
@@ -0,0 +1,116 @@
+package kv
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"go.k6.io/k6/event"
+)
+
+// registry keeps track of the stores opened by the clients, so that all the
+// clients opened with the same name share the same database. It is safe for
+// concurrent use by all the VUs.
+type registry struct {
+	mu     sync.Mutex
+	stores map[string]*store
+}
+
+func newRegistry() *registry {
+	return &registry{stores: make(map[string]*store)}
+}
+
+// open returns the store with the given name, opening its database with the
+// given options if nobody opened it yet. The first opener wins: the options
+// of later openers are ignored. Each successful call must be balanced by a
+// call to release. Whatever the references left, the database is closed
+// when the test exits, as notified by the given events system. Background
+// tasks log through the given logger.
+func (r *registry) open(opts options, events *event.System, logger logrus.FieldLogger) (*store, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if s, exists := r.stores[opts.name]; exists {
+		s.refs++
+		return s, nil
+	}
+
+	db, err := openDB(opts)
+	if err != nil {
+		return nil, err
+	}
+	s := newStore(opts, db)
+	if opts.restoreFrom != "" {
+		if err := s.restore(opts.restoreFrom); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+	if opts.metricsPort > 0 {
+		if err := s.serveMetrics(opts.metricsPort); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+	s.refs = 1
+	r.stores[opts.name] = s
+	if events != nil {
+		r.closeOnExit(s, events)
+	}
+	if opts.gcInterval > 0 {
+		s.scheduleGC(opts.gcInterval, opts.gcDiscardRatio, logger)
+	}
+	if opts.refreshFrom != "" {
+		s.scheduleRefresh(opts.refreshFrom, opts.refreshInterval, opts.refreshHeaders, logger)
+	}
+	return s, nil
+}
+
+// release drops a reference to the given store, closing its database once
+// no client uses it anymore.
+func (r *registry) release(s *store) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if s.refs == 0 {
+		return nil
+	}
+	s.refs--
+	if s.refs > 0 {
+		return nil
+	}
+	return r.close(s)
+}
+
+// closeOnExit closes the given store when the test exits, so that its
+// database is flushed and its directory unlocked even if the script never
+// closed its clients.
+func (r *registry) closeOnExit(s *store, events *event.System) {
+	sid, ch := events.Subscribe(event.Exit)
+	go func() {
+		e, ok := <-ch
+		if !ok {
+			return
+		}
+		defer e.Done()
+		events.Unsubscribe(sid)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		_ = r.close(s)
+	}()
+}
+
+// close closes the database of the given store and removes it from the
+// registry, so that it can be opened again. It is a no-op if the store was
+// already closed. The registry mutex must be held.
+func (r *registry) close(s *store) error {
+	if r.stores[s.name] != s {
+		return nil
+	}
+	delete(r.stores, s.name)
+	s.refs = 0
+	close(s.done)
+	s.stopMerges()
+	s.releaseSequences()
+	return wrapError(s.db.Close())
+}
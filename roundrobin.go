@@ -0,0 +1,73 @@
+package kv
+
+import (
+	"errors"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// roundRobinKey returns the key holding the last key returned by
+// NextRoundRobin for the given prefix.
+func roundRobinKey(prefix string) []byte {
+	return internalKey("roundrobin", prefix)
+}
+
+// NextRoundRobin returns the entry following the one it returned last for
+// the given prefix, across all the VUs, in key order, and starts over from
+// the first entry after the last one. It returns null if no key starts with
+// the given prefix.
+func (c *Client) NextRoundRobin(prefix string) (_ interface{}, err error) {
+	var entry *Entry
+	start := time.Now()
+	defer func() { c.trackEntry("nextRoundRobin", entry, start, err) }()
+	ns := c.namespace()
+	prefix = ns + prefix
+	err = c.updateLocked(func(txn *badger.Txn) error {
+		var last []byte
+		item, err := txn.Get(roundRobinKey(prefix))
+		switch {
+		case errors.Is(err, badger.ErrKeyNotFound):
+		case err != nil:
+			return err
+		default:
+			if last, err = item.ValueCopy(nil); err != nil {
+				return err
+			}
+		}
+		if last != nil {
+			if entry, err = c.nextEntry(txn, prefix, last); err != nil {
+				return err
+			}
+		}
+		if entry == nil {
+			if entry, err = c.edgeEntry(txn, prefix, false); err != nil || entry == nil {
+				return err
+			}
+		}
+		return txn.Set(roundRobinKey(prefix), []byte(entry.Key))
+	})
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	entry.Key = entry.Key[len(ns):]
+	return entry, nil
+}
+
+// nextEntry returns the first entry where the key starts with the given
+// prefix and follows the given key, and nil if there is none.
+func (c *Client) nextEntry(txn *badger.Txn, prefix string, after []byte) (*Entry, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchSize = 10
+	opts.Prefix = []byte(prefix)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	for it.Seek(append(after, 0)); it.Valid(); it.Next() {
+		item := it.Item()
+		if isInternalKey(item.Key()) || expired(item) {
+			continue
+		}
+		return c.itemEntry(string(item.Key()), item)
+	}
+	return nil, nil
+}
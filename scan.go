@@ -0,0 +1,290 @@
+package kv
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/grafana/sobek"
+)
+
+// ScanOptions holds the settings of the scans.
+type ScanOptions struct {
+	// Reverse returns the entries in reverse key order.
+	Reverse bool `js:"reverse"`
+}
+
+// iteratorSeek returns the key to seek for iterating over the keys starting
+// with the given prefix, in reverse order when reverse is true.
+func iteratorSeek(prefix []byte, reverse bool) []byte {
+	seek := append([]byte{}, prefix...)
+	if reverse {
+		// Keys are UTF-8 strings, which never contain 0xFF bytes.
+		seek = append(seek, 0xFF)
+	}
+	return seek
+}
+
+// scanEntries returns the entries where the key starts with the given
+// prefix and matches the given function, if not nil, in key order, or in
+// reverse key order when reverse is true. A limit lower or equal to 0 means
+// no limit. The keys given to match and returned are relative to the
+// namespace of the client.
+func (c *Client) scanEntries(prefix string, match func(key string) bool, limit int, reverse bool) ([]Entry, error) {
+	entries, _, err := c.scanPage(prefix, "", match, limit, reverse)
+	return entries, err
+}
+
+// scanPage is scanEntries starting after the given cursor key, if not empty,
+// which also reports whether there are more entries than the limit.
+func (c *Client) scanPage(
+	prefix string, cursor string, match func(key string) bool, limit int, reverse bool,
+) (entries []Entry, more bool, err error) {
+	entries = make([]Entry, 0)
+	ns := c.namespace()
+	err = c.view(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(ns + prefix)
+		opts.Reverse = reverse
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		seek := iteratorSeek(opts.Prefix, reverse)
+		if cursor != "" {
+			seek = []byte(ns + cursor)
+		}
+		for it.Seek(seek); it.Valid(); it.Next() {
+			item := it.Item()
+			if isInternalKey(item.Key()) || expired(item) {
+				continue
+			}
+			key := string(item.Key()[len(ns):])
+			if cursor != "" && key == cursor {
+				continue
+			}
+			if match != nil && !match(key) {
+				continue
+			}
+			if limit > 0 && len(entries) >= limit {
+				more = true
+				break
+			}
+			entry, err := c.itemEntry(key, item)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, *entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return entries, more, nil
+}
+
+// ScanRegex returns the entries where the key matches the given regular
+// expression, in key order. A limit lower or equal to 0 means no limit.
+func (c *Client) ScanRegex(pattern string, limit int, opts ScanOptions) (_ []Entry, err error) {
+	start := time.Now()
+	defer func() { c.track("scanRegex", pattern, 0, start, err) }()
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, newInvalidArgumentError("invalid regular expression %q: %s", pattern, err)
+	}
+	return c.scanEntries("", re.MatchString, limit, opts.Reverse)
+}
+
+// ScanGlob returns the entries where the key matches the given glob pattern,
+// in which * matches any sequence of characters and ? any single character,
+// in key order. Only the keys starting with the literal prefix of the
+// pattern, before its first wildcard, are scanned. A limit lower or equal to
+// 0 means no limit.
+func (c *Client) ScanGlob(pattern string, limit int, opts ScanOptions) (_ []Entry, err error) {
+	start := time.Now()
+	defer func() { c.track("scanGlob", pattern, 0, start, err) }()
+	prefix := pattern
+	if i := strings.IndexAny(pattern, "*?"); i >= 0 {
+		prefix = pattern[:i]
+	}
+	re := globRegexp(pattern)
+	return c.scanEntries(prefix, re.MatchString, limit, opts.Reverse)
+}
+
+// eachEntry calls fn with each entry where the key starts with the given
+// prefix, in key order, or in reverse key order when reverse is true, until
+// fn returns false or an error, which is returned as is. The keys given to
+// fn are relative to the namespace of the client.
+func (c *Client) eachEntry(prefix string, reverse bool, fn func(entry *Entry) (bool, error)) error {
+	ns := c.namespace()
+	var fnErr error
+	err := c.view(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(ns + prefix)
+		opts.Reverse = reverse
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(iteratorSeek(opts.Prefix, reverse)); it.Valid(); it.Next() {
+			item := it.Item()
+			if isInternalKey(item.Key()) || expired(item) {
+				continue
+			}
+			entry, err := c.itemEntry(string(item.Key()[len(ns):]), item)
+			if err != nil {
+				return err
+			}
+			var next bool
+			next, fnErr = fn(entry)
+			if fnErr != nil {
+				return fnErr
+			}
+			if !next {
+				break
+			}
+		}
+		return nil
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	return err
+}
+
+// Filter returns the entries where the key starts with the given prefix and
+// for which the given predicate, called with the key and the value, returns
+// a truthy value, in key order, or in reverse key order with the Reverse
+// option. A limit lower or equal to 0 means no limit. If the predicate
+// throws, the scan stops and the exception is rethrown.
+func (c *Client) Filter(prefix string, predicate sobek.Value, limit int, opts ScanOptions) (_ []Entry, err error) {
+	start := time.Now()
+	defer func() { c.track("filter", prefix, 0, start, err) }()
+	call, ok := sobek.AssertFunction(predicate)
+	if !ok {
+		return nil, newInvalidArgumentError("filter requires a function")
+	}
+
+	rt := c.vu.Runtime()
+	entries := make([]Entry, 0)
+	err = c.eachEntry(prefix, opts.Reverse, func(entry *Entry) (bool, error) {
+		keep, err := call(sobek.Undefined(), rt.ToValue(entry.Key), rt.ToValue(entry.Value))
+		if err != nil {
+			return false, err
+		}
+		if keep.ToBoolean() {
+			entries = append(entries, *entry)
+		}
+		return limit <= 0 || len(entries) < limit, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ForEach calls the given callback with the key and the value of each entry
+// where the key starts with the given prefix, in key order, or in reverse
+// key order with the Reverse option, without loading them all at once, and
+// returns the number of entries visited. The iteration stops when the
+// callback returns false. If the callback throws, the iteration stops and
+// the exception is rethrown.
+func (c *Client) ForEach(prefix string, callback sobek.Value, opts ScanOptions) (_ int, err error) {
+	start := time.Now()
+	defer func() { c.track("forEach", prefix, 0, start, err) }()
+	call, ok := sobek.AssertFunction(callback)
+	if !ok {
+		return 0, newInvalidArgumentError("forEach requires a function")
+	}
+
+	rt := c.vu.Runtime()
+	var visited int
+	err = c.eachEntry(prefix, opts.Reverse, func(entry *Entry) (bool, error) {
+		visited++
+		next, err := call(sobek.Undefined(), rt.ToValue(entry.Key), rt.ToValue(entry.Value))
+		if err != nil {
+			return false, err
+		}
+		// Only an explicit false stops the iteration, not undefined.
+		return !next.StrictEquals(rt.ToValue(false)), nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return visited, nil
+}
+
+// Range returns the entries where the key is between startKey, included, and
+// endKey, excluded, in key order, or in reverse key order with the Reverse
+// option. An empty endKey means no upper bound, and a limit lower or equal to
+// 0 means no limit.
+func (c *Client) Range(startKey string, endKey string, limit int, opts ScanOptions) (_ []Entry, err error) {
+	start := time.Now()
+	defer func() { c.track("range", startKey, 0, start, err) }()
+	entries := make([]Entry, 0)
+	ns := c.namespace()
+	err = c.view(func(txn *badger.Txn) error {
+		iterOpts := badger.DefaultIteratorOptions
+		iterOpts.PrefetchValues = false
+		iterOpts.Prefix = []byte(ns)
+		iterOpts.Reverse = opts.Reverse
+		it := txn.NewIterator(iterOpts)
+		defer it.Close()
+		seek := []byte(ns + startKey)
+		if opts.Reverse {
+			// Seeking endKey lands on it or on the key right before it, while
+			// no endKey starts from the last key of the namespace.
+			seek = []byte(ns + endKey)
+			if endKey == "" {
+				seek = iteratorSeek(iterOpts.Prefix, true)
+			}
+		}
+		for it.Seek(seek); it.Valid(); it.Next() {
+			if limit > 0 && len(entries) >= limit {
+				break
+			}
+			item := it.Item()
+			if isInternalKey(item.Key()) || expired(item) {
+				continue
+			}
+			key := string(item.Key()[len(ns):])
+			if endKey != "" && key >= endKey {
+				if opts.Reverse {
+					continue
+				}
+				break
+			}
+			if opts.Reverse && key < startKey {
+				break
+			}
+			entry, err := c.itemEntry(key, item)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, *entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// globRegexp returns the regular expression matching the same strings as the
+// given glob pattern.
+func globRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^(?s:")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString(")$")
+	return regexp.MustCompile(b.String())
+}
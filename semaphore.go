@@ -0,0 +1,58 @@
+package kv
+
+import (
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/grafana/sobek"
+)
+
+// semaphoreKey returns the key holding the number of permits taken from the
+// given semaphore.
+func semaphoreKey(name string) []byte {
+	return internalKey("semaphore", name)
+}
+
+// SemaphoreAcquire returns a promise resolved once a permit of the given
+// semaphore, which has the given number of permits, is taken, or rejected
+// with a Timeout error if no permit is released within the given timeout in
+// milliseconds. A timeout lower or equal to 0 means waiting until the end of
+// the test. Each permit must be given back with SemaphoreRelease.
+func (c *Client) SemaphoreAcquire(name string, permits int64, timeoutMs int64) *sobek.Promise {
+	key := semaphoreKey(c.scoped(name))
+	return c.waitPromise("semaphoreAcquire", name, key, timeoutMs, func() (bool, error) {
+		if permits <= 0 {
+			return false, newInvalidArgumentError("semaphore permits must be positive, got %d", permits)
+		}
+		var acquired bool
+		err := c.updateLocked(func(txn *badger.Txn) error {
+			taken, err := readCount(txn, key)
+			if err != nil || taken >= permits {
+				return err
+			}
+			acquired = true
+			return writeCount(txn, key, taken+1)
+		})
+		return acquired, err
+	}, func() interface{} {
+		return true
+	})
+}
+
+// SemaphoreRelease gives back a permit of the given semaphore, and returns
+// false if no permit was taken.
+func (c *Client) SemaphoreRelease(name string) (_ bool, err error) {
+	start := time.Now()
+	defer func() { c.track("semaphoreRelease", name, 0, start, err) }()
+	key := semaphoreKey(c.scoped(name))
+	var released bool
+	err = c.updateLocked(func(txn *badger.Txn) error {
+		taken, err := readCount(txn, key)
+		if err != nil || taken == 0 {
+			return err
+		}
+		released = true
+		return writeCount(txn, key, taken-1)
+	})
+	return released, err
+}
@@ -0,0 +1,74 @@
+package kv
+
+import (
+	"sync"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// defaultSequenceBandwidth is the number of IDs leased at once by each
+// sequence when the sequenceBandwidth option is not set.
+const defaultSequenceBandwidth = 100
+
+// sequences holds the Badger sequences used by NextSequence, which lease
+// ranges of IDs from the database and must be released when it is closed.
+type sequences struct {
+	mu   sync.Mutex
+	seqs map[string]*badger.Sequence
+}
+
+// sequenceKey returns the key holding the lease of the given sequence.
+func sequenceKey(name string) []byte {
+	return internalKey("sequence", name)
+}
+
+// NextSequence returns the next ID of the given sequence, starting at 1. The
+// IDs are unique across all the VUs and increasing, but the ones leased and
+// not returned before the database is closed are skipped.
+func (c *Client) NextSequence(name string) (_ int64, err error) {
+	start := time.Now()
+	defer func() { c.track("nextSequence", name, 0, start, err) }()
+	if err = c.checkWritable(); err != nil {
+		return 0, err
+	}
+	seq, err := c.sequence(c.scoped(name))
+	if err != nil {
+		return 0, wrapError(err)
+	}
+	n, err := seq.Next()
+	if err != nil {
+		return 0, wrapError(err)
+	}
+	return int64(n) + 1, nil
+}
+
+// sequence returns the Badger sequence with the given name, creating it on
+// first use.
+func (s *store) sequence(name string) (*badger.Sequence, error) {
+	s.sequences.mu.Lock()
+	defer s.sequences.mu.Unlock()
+	if seq, ok := s.sequences.seqs[name]; ok {
+		return seq, nil
+	}
+	seq, err := s.db.GetSequence(sequenceKey(name), uint64(s.opts.sequenceBandwidth))
+	if err != nil {
+		return nil, err
+	}
+	if s.sequences.seqs == nil {
+		s.sequences.seqs = make(map[string]*badger.Sequence)
+	}
+	s.sequences.seqs[name] = seq
+	return seq, nil
+}
+
+// releaseSequences releases the sequences, returning their unused IDs, before
+// the database is closed.
+func (s *store) releaseSequences() {
+	s.sequences.mu.Lock()
+	defer s.sequences.mu.Unlock()
+	for name, seq := range s.sequences.seqs {
+		_ = seq.Release()
+		delete(s.sequences.seqs, name)
+	}
+}
@@ -0,0 +1,51 @@
+package kv
+
+import (
+	"errors"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/grafana/sobek"
+)
+
+// Signal sets the given key with the given value, resolving the promises of
+// the VUs awaiting it.
+func (c *Client) Signal(key string, value interface{}) (err error) {
+	var data []byte
+	start := time.Now()
+	defer func() { c.track("signal", key, len(data), start, err) }()
+	data, valueType, err := encodeValue(value)
+	if err != nil {
+		return &Error{Name: InvalidArgumentError, Message: err.Error(), Key: key}
+	}
+	return c.update(func(txn *badger.Txn) error {
+		return txn.SetEntry(c.newEntry(c.key(key), data, valueType))
+	})
+}
+
+// Await returns a promise resolved with the value of the given key as soon
+// as it is set, immediately if it already is, or rejected with a Timeout
+// error if it isn't within the given timeout in milliseconds.
+func (c *Client) Await(key string, timeoutMs int64) *sobek.Promise {
+	var val []byte
+	var valueType byte
+	k := c.key(key)
+	return c.waitPromise("await", key, k, timeoutMs, func() (bool, error) {
+		var found bool
+		err := c.view(func(txn *badger.Txn) error {
+			item, err := getItem(txn, k)
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			found = true
+			val, valueType, err = itemValue(item)
+			return err
+		})
+		return found, err
+	}, func() interface{} {
+		return c.decodeValue(valueType, val)
+	})
+}
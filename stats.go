@@ -0,0 +1,50 @@
+package kv
+
+// Stats describes the state of a database, as returned by Client.Stats.
+type Stats struct {
+	// LSMSize is the size in bytes of the LSM tree.
+	LSMSize int64 `js:"lsmSize"`
+
+	// VLogSize is the size in bytes of the value log.
+	VLogSize int64 `js:"vlogSize"`
+
+	// KeyCount is the number of keys in the LSM tables. It is an estimate:
+	// it counts the keys still in memory tables out, and the deleted,
+	// expired and overwritten keys not compacted yet in.
+	KeyCount uint64 `js:"keyCount"`
+
+	// Tables is the number of LSM tables.
+	Tables int `js:"tables"`
+
+	// PendingCompactions is the number of LSM levels which need to be
+	// compacted.
+	PendingCompactions int `js:"pendingCompactions"`
+}
+
+// Stats returns the sizes of the database, its estimated number of keys and
+// its number of pending compactions.
+func (c *Client) Stats() (Stats, error) {
+	if c.closed {
+		return Stats{}, c.closedError()
+	}
+	return c.stats(), nil
+}
+
+// stats gathers the statistics of the database.
+func (s *store) stats() Stats {
+	var stats Stats
+	stats.LSMSize, stats.VLogSize = s.db.Size()
+
+	tables := s.db.Tables()
+	stats.Tables = len(tables)
+	for _, t := range tables {
+		stats.KeyCount += uint64(t.KeyCount)
+	}
+
+	for _, l := range s.db.Levels() {
+		if l.Score >= 1 {
+			stats.PendingCompactions++
+		}
+	}
+	return stats
+}
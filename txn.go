@@ -0,0 +1,171 @@
+package kv
+
+import (
+	"errors"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/grafana/sobek"
+)
+
+// Txn is the JS object given to the callback of Client.Transaction. Its
+// writes are only visible to the other clients once the callback returns.
+type Txn struct {
+	c    *Client
+	txn  *badger.Txn
+	done bool
+}
+
+// Transaction calls fn with a Txn, and commits all the writes made through
+// it atomically once fn returns, returning the value returned by fn. If fn
+// throws, the writes are discarded and the exception is rethrown. If another
+// client wrote one of the keys read by fn in the meantime, a Conflict error
+// is thrown. Unlike the other writes, the transaction is not retried on
+// conflicts, as fn may have side effects.
+func (c *Client) Transaction(fn sobek.Value) (_ sobek.Value, err error) {
+	start := time.Now()
+	defer func() { c.track("transaction", "", 0, start, err) }()
+	call, ok := sobek.AssertFunction(fn)
+	if !ok {
+		return nil, newInvalidArgumentError("transaction requires a function")
+	}
+
+	if err := c.checkWritable(); err != nil {
+		return nil, err
+	}
+	var result sobek.Value
+	var callErr error
+	err = c.db.Update(func(txn *badger.Txn) error {
+		t := &Txn{c: c, txn: txn}
+		defer func() { t.done = true }()
+		result, callErr = call(sobek.Undefined(), c.vu.Runtime().ToValue(t))
+		return callErr
+	})
+	if callErr != nil {
+		return nil, callErr
+	}
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return result, nil
+}
+
+// Get returns the value for the given key as seen by the transaction, or
+// null if the key does not exist.
+func (t *Txn) Get(key string) (interface{}, error) {
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+	item, err := getItem(t.txn, t.c.key(key))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return t.c.missing(key)
+	}
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	valCopy, valueType, err := itemValue(item)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return t.c.decodeValue(valueType, valCopy), nil
+}
+
+// Set the given key with the given value within the transaction, storing its
+// type as Client.Set does.
+func (t *Txn) Set(key string, value interface{}) error {
+	if err := t.check(); err != nil {
+		return err
+	}
+	data, valueType, err := encodeValue(value)
+	if err != nil {
+		return &Error{Name: InvalidArgumentError, Message: err.Error(), Key: key}
+	}
+	return wrapError(t.txn.SetEntry(t.c.newEntry(t.c.key(key), data, valueType)))
+}
+
+// Delete the given key within the transaction.
+func (t *Txn) Delete(key string) error {
+	if err := t.check(); err != nil {
+		return err
+	}
+	return wrapError(t.txn.Delete(t.c.key(key)))
+}
+
+// check returns an error if the transaction is used after its callback
+// returned.
+func (t *Txn) check() error {
+	if t.done {
+		return newInvalidArgumentError("the transaction is already finished")
+	}
+	return nil
+}
+
+// Batch is the JS object returned by Client.Batch, collecting writes to
+// apply together with Commit.
+type Batch struct {
+	c   *Client
+	ops []batchOp
+}
+
+// batchOp is a write collected by a Batch.
+type batchOp struct {
+	key       string
+	value     []byte
+	valueType byte
+	delete    bool
+}
+
+// Batch returns a new Batch, of which set and delete calls can be chained
+// before a final commit:
+//
+//	client.batch().set("a", "1").delete("b").commit()
+func (c *Client) Batch() *Batch {
+	return &Batch{c: c}
+}
+
+// Set adds the write of the given key with the given value to the batch,
+// storing its type as Client.Set does.
+func (b *Batch) Set(key string, value interface{}) (*Batch, error) {
+	data, valueType, err := encodeValue(value)
+	if err != nil {
+		return nil, &Error{Name: InvalidArgumentError, Message: err.Error(), Key: key}
+	}
+	b.ops = append(b.ops, batchOp{key: key, value: data, valueType: valueType})
+	return b, nil
+}
+
+// Delete adds the deletion of the given key to the batch.
+func (b *Batch) Delete(key string) *Batch {
+	b.ops = append(b.ops, batchOp{key: key, delete: true})
+	return b
+}
+
+// Commit applies the writes of the batch through a single write batch, in
+// the order they were added, and returns their number. The batch is empty
+// afterwards and can be reused.
+func (b *Batch) Commit() (_ int, err error) {
+	start := time.Now()
+	defer func() { b.c.track("batchCommit", "", 0, start, err) }()
+	if err := b.c.checkWritable(); err != nil {
+		return 0, err
+	}
+	wb := b.c.db.NewWriteBatch()
+	defer wb.Cancel()
+	for _, op := range b.ops {
+		var err error
+		if op.delete {
+			err = wb.Delete(b.c.key(op.key))
+		} else {
+			err = wb.SetEntry(b.c.newEntry(b.c.key(op.key), op.value, op.valueType))
+		}
+		if err != nil {
+			return 0, wrapError(err)
+		}
+	}
+	if err := wb.Flush(); err != nil {
+		return 0, wrapError(err)
+	}
+	n := len(b.ops)
+	b.ops = nil
+	return n, nil
+}
@@ -0,0 +1,161 @@
+package kv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/js/common"
+)
+
+// Value types, stored in the user metadata of the entries so that values are
+// returned with the type they were set with.
+const (
+	valueTypeString byte = iota
+	valueTypeBytes
+	valueTypeNumber
+	valueTypeBool
+	valueTypeJSON
+)
+
+// encodeValue returns the bytes to store for the given value exported from
+// JS, along with its type.
+func encodeValue(value interface{}) ([]byte, byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, 0, errors.New("value must not be null or undefined")
+	case string:
+		return []byte(v), valueTypeString, nil
+	case bool:
+		return []byte(strconv.FormatBool(v)), valueTypeBool, nil
+	case int64:
+		return []byte(strconv.FormatInt(v, 10)), valueTypeNumber, nil
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'f', -1, 64)), valueTypeNumber, nil
+	case sobek.ArrayBuffer, *sobek.ArrayBuffer, []byte:
+		data, err := common.ToBytes(v)
+		return data, valueTypeBytes, err
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, 0, fmt.Errorf("unable to encode value as JSON: %w", err)
+		}
+		return data, valueTypeJSON, nil
+	}
+}
+
+// decodeValue returns the JS value of a value stored with the given type.
+// Values that don't match their type, for instance because they were
+// modified by Append, are returned as strings.
+func (c *Client) decodeValue(valueType byte, value []byte) interface{} {
+	switch valueType {
+	case valueTypeBytes:
+		return c.vu.Runtime().NewArrayBuffer(value)
+	case valueTypeNumber:
+		if f, err := strconv.ParseFloat(string(value), 64); err == nil {
+			return f
+		}
+	case valueTypeBool:
+		if b, err := strconv.ParseBool(string(value)); err == nil {
+			return b
+		}
+	case valueTypeJSON:
+		var v interface{}
+		if err := json.Unmarshal(value, &v); err == nil {
+			return v
+		}
+	}
+	return string(value)
+}
+
+// SetBytes sets the given key with the content of the given ArrayBuffer or
+// typed array, without any string conversion.
+func (c *Client) SetBytes(key string, value interface{}) (err error) {
+	var data []byte
+	start := time.Now()
+	defer func() { c.track("set", key, len(data), start, err) }()
+	data, err = common.ToBytes(value)
+	if err != nil {
+		return newInvalidArgumentError("invalid binary value for key %q: %s", key, err)
+	}
+	err = c.update(func(txn *badger.Txn) error {
+		return txn.SetEntry(c.newEntry(c.key(key), data, valueTypeBytes))
+	})
+	return err
+}
+
+// GetBytes returns the value of the given key as an ArrayBuffer, or null if
+// the key does not exist and the client wasn't created with throwOnMissing.
+func (c *Client) GetBytes(key string) (_ interface{}, err error) {
+	var valCopy []byte
+	start := time.Now()
+	defer func() { c.track("get", key, len(valCopy), start, err) }()
+	var found bool
+	valCopy, found, err = c.readValue(key)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return c.missing(key)
+	}
+	return c.vu.Runtime().NewArrayBuffer(valCopy), nil
+}
+
+// GetInt returns the value of the given key parsed as an integer, or null if
+// the key does not exist and the client wasn't created with throwOnMissing.
+func (c *Client) GetInt(key string) (interface{}, error) {
+	return c.getTyped("getInt", key, "an integer", func(s string) (interface{}, error) {
+		return strconv.ParseInt(s, 10, 64)
+	})
+}
+
+// GetFloat returns the value of the given key parsed as a number, or null if
+// the key does not exist and the client wasn't created with throwOnMissing.
+func (c *Client) GetFloat(key string) (interface{}, error) {
+	return c.getTyped("getFloat", key, "a number", func(s string) (interface{}, error) {
+		return strconv.ParseFloat(s, 64)
+	})
+}
+
+// GetBool returns the value of the given key parsed as a boolean, accepting
+// the values understood by strconv.ParseBool such as "true" or "0", or null
+// if the key does not exist and the client wasn't created with
+// throwOnMissing.
+func (c *Client) GetBool(key string) (interface{}, error) {
+	return c.getTyped("getBool", key, "a boolean", func(s string) (interface{}, error) {
+		return strconv.ParseBool(s)
+	})
+}
+
+// getTyped returns the value of the given key converted by parse, throwing an
+// InvalidArgument error describing the expected type if it fails. The
+// operation is tracked as op.
+func (c *Client) getTyped(
+	op string, key string, typeName string, parse func(string) (interface{}, error),
+) (_ interface{}, err error) {
+	var valCopy []byte
+	start := time.Now()
+	defer func() { c.track(op, key, len(valCopy), start, err) }()
+	var found bool
+	valCopy, found, err = c.readValue(key)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return c.missing(key)
+	}
+	v, err := parse(string(valCopy))
+	if err != nil {
+		return nil, &Error{
+			Name:    InvalidArgumentError,
+			Message: fmt.Sprintf("value %q of key %s is not %s", valCopy, key, typeName),
+			Key:     key,
+			cause:   err,
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,92 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/dgraph-io/badger/v4/pb"
+	"github.com/grafana/sobek"
+)
+
+// waitPollInterval is the interval at which waitFor retries regardless of
+// the writes, for the conditions that don't depend on writes only, such as
+// the queue items scheduled for a later time.
+const waitPollInterval = 100 * time.Millisecond
+
+// waitFor calls try until it returns true or an error, the given context is
+// done or the store is closed. try is called again each time a key starting
+// with the given prefix is written, and at least every waitPollInterval.
+func (s *store) waitFor(ctx context.Context, prefix []byte, try func() (bool, error)) error {
+	subCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	written := make(chan struct{}, 1)
+	go func() {
+		_ = s.db.Subscribe(subCtx, func(*badger.KVList) error {
+			select {
+			case written <- struct{}{}:
+			default:
+			}
+			return nil
+		}, []pb.Match{{Prefix: prefix}})
+	}()
+
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+	for {
+		ok, err := try()
+		if ok || err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.done:
+			return badger.ErrDBClosed
+		case <-written:
+		case <-ticker.C:
+		}
+	}
+}
+
+// waitPromise returns a promise resolved with the value returned by result
+// once try returns true, as waited for by waitFor on the given prefix, or
+// rejected with a Timeout error if try doesn't within the given timeout in
+// milliseconds. A timeout lower or equal to 0 means waiting until the end of
+// the test. try runs outside of the event loop and must not use the JS
+// runtime, unlike result. The operation is tracked as op on the given key.
+func (c *Client) waitPromise(
+	op string, key string, prefix []byte, timeoutMs int64, try func() (bool, error), result func() interface{},
+) *sobek.Promise {
+	rt := c.vu.Runtime()
+	promise, resolve, reject := rt.NewPromise()
+	callback := c.vu.RegisterCallback()
+
+	ctx := c.vu.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cancel := func() {}
+	if timeoutMs > 0 {
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond)
+	}
+
+	start := time.Now()
+	go func() {
+		defer cancel()
+		err := c.waitFor(ctx, prefix, try)
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = &Error{Name: TimeoutError, Message: fmt.Sprintf("%s timed out after %dms", op, timeoutMs), Key: key}
+		}
+		callback(func() error {
+			c.track(op, key, 0, start, err)
+			if err != nil {
+				return reject(rt.NewGoError(wrapError(err)))
+			}
+			return resolve(result())
+		})
+	}()
+	return promise
+}
@@ -0,0 +1,62 @@
+package kv
+
+import (
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// windowKey returns the key of the sliding window counter with the given
+// name, under which its events are stored.
+func windowKey(name string) []byte {
+	return internalKey("window", name)
+}
+
+// SlidingWindowIncr records an event in the sliding window counter with the
+// given name, and returns the number of events recorded by all the VUs
+// within the given number of milliseconds, including this one.
+func (c *Client) SlidingWindowIncr(name string, windowMs int64) (_ int64, err error) {
+	start := time.Now()
+	defer func() { c.track("slidingWindowIncr", name, 0, start, err) }()
+	key := windowKey(c.scoped(name))
+	if windowMs <= 0 {
+		return 0, newInvalidArgumentError("sliding window must be positive, got %d", windowMs)
+	}
+	var count int64
+	err = c.updateLocked(func(txn *badger.Txn) error {
+		now := time.Now().UnixMilli()
+		n, err := nextCounter(txn, internalKey("windowseq", c.scoped(name)))
+		if err != nil {
+			return err
+		}
+		// The TTL only cleans up the events of abandoned windows, the count
+		// relies on the timestamps. It is rounded up to a whole second, so
+		// that the events never expire within the window.
+		suffix := append(encodeIndex(now), encodeIndex(n)...)
+		event := withTTL(badger.NewEntry(itemKey(key, suffix), nil), time.Duration(windowMs)*time.Millisecond)
+		if err := txn.SetEntry(event); err != nil {
+			return err
+		}
+
+		// The events older than the window are deleted, as they may have
+		// been recorded with a longer one.
+		prefix := itemKey(key, nil)
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			key := it.Item().KeyCopy(nil)
+			if decodeIndex(key[len(prefix):len(prefix)+8]) > now-windowMs {
+				count++
+				continue
+			}
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return count, err
+}
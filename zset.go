@@ -0,0 +1,245 @@
+package kv
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// ScoredMember is a member of a sorted set along with its score.
+type ScoredMember struct {
+	Member string  `js:"member"`
+	Score  float64 `js:"score"`
+}
+
+// A sorted set stores the score of each member under its member key, and
+// indexes the members under score keys, made of the encoded score followed
+// by the member, so that iterating over the score keys returns the members
+// ordered by score.
+const (
+	zsetMemberTag = 'm'
+	zsetScoreTag  = 's'
+)
+
+// zsetMemberKey returns the key holding the score of the given member of the
+// sorted set of the given key.
+func zsetMemberKey(key string, member string) []byte {
+	return itemKey(internalKey("zset", key), append([]byte{zsetMemberTag}, member...))
+}
+
+// zsetScorePrefix returns the prefix of the score keys of the sorted set of
+// the given key.
+func zsetScorePrefix(key string) []byte {
+	return itemKey(internalKey("zset", key), []byte{zsetScoreTag})
+}
+
+// zsetScoreKey returns the score key of the given member with the given
+// score in the sorted set of the given key.
+func zsetScoreKey(key string, member string, score float64) []byte {
+	return append(append(zsetScorePrefix(key), encodeScore(score)...), member...)
+}
+
+// encodeScore encodes the given score so that the byte order of the encoded
+// scores matches their numeric order.
+func encodeScore(score float64) []byte {
+	bits := math.Float64bits(score)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, bits)
+	return b
+}
+
+// decodeScore decodes a score encoded by encodeScore.
+func decodeScore(b []byte) float64 {
+	bits := binary.BigEndian.Uint64(b)
+	if bits&(1<<63) != 0 {
+		bits &^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	return math.Float64frombits(bits)
+}
+
+// ZAdd sets the score of the given member of the sorted set of the given
+// key, and returns true if the member was added to the set.
+func (c *Client) ZAdd(key string, member string, score float64) (_ bool, err error) {
+	start := time.Now()
+	defer func() { c.track("zAdd", key, 0, start, err) }()
+	if math.IsNaN(score) {
+		return false, newInvalidArgumentError("score of member %q must be a number", member)
+	}
+	var added bool
+	err = c.updateZSet(func(txn *badger.Txn) error {
+		_, found, err := c.zsetSetScore(txn, c.scoped(key), member, func(float64) float64 { return score })
+		added = !found
+		return err
+	})
+	return added, err
+}
+
+// ZIncrBy adds the given delta to the score of the given member of the sorted
+// set of the given key, a missing member having a score of 0, and returns the
+// new score.
+func (c *Client) ZIncrBy(key string, member string, delta float64) (_ float64, err error) {
+	start := time.Now()
+	defer func() { c.track("zIncrBy", key, 0, start, err) }()
+	if math.IsNaN(delta) {
+		return 0, newInvalidArgumentError("increment of member %q must be a number", member)
+	}
+	var score float64
+	err = c.updateZSet(func(txn *badger.Txn) error {
+		var err error
+		score, _, err = c.zsetSetScore(txn, c.scoped(key), member, func(current float64) float64 { return current + delta })
+		return err
+	})
+	return score, err
+}
+
+// updateZSet runs fn in a read-write transaction updating a sorted set.
+// Score updates are serialized, as concurrent updates of the same member,
+// such as increments of a hot member by many VUs, would conflict.
+func (c *Client) updateZSet(fn func(txn *badger.Txn) error) error {
+	c.seqMu.Lock()
+	defer c.seqMu.Unlock()
+	return c.update(fn)
+}
+
+// zsetSetScore sets the score of the given member to the value returned by
+// update for its current score, and returns the new score along with whether
+// the member was already in the set.
+func (c *Client) zsetSetScore(
+	txn *badger.Txn, key string, member string, update func(float64) float64,
+) (float64, bool, error) {
+	memberKey := zsetMemberKey(key, member)
+	var current float64
+	item, err := txn.Get(memberKey)
+	found := err == nil
+	switch {
+	case errors.Is(err, badger.ErrKeyNotFound):
+	case err != nil:
+		return 0, false, err
+	default:
+		if err := item.Value(func(val []byte) error {
+			current = math.Float64frombits(binary.BigEndian.Uint64(val))
+			return nil
+		}); err != nil {
+			return 0, false, err
+		}
+		if err := txn.Delete(zsetScoreKey(key, member, current)); err != nil {
+			return 0, false, err
+		}
+	}
+
+	score := update(current)
+	val := make([]byte, 8)
+	binary.BigEndian.PutUint64(val, math.Float64bits(score))
+	if err := txn.Set(memberKey, val); err != nil {
+		return 0, false, err
+	}
+	return score, found, txn.Set(zsetScoreKey(key, member, score), nil)
+}
+
+// ZRange returns the members of the sorted set of the given key from rank
+// start to rank stop, both included, along with their scores. Members are
+// ranked by ascending score, or by descending score when reverse is true,
+// and negative ranks count from the end.
+func (c *Client) ZRange(key string, start int64, stop int64, reverse bool) (_ []ScoredMember, err error) {
+	began := time.Now()
+	defer func() { c.track("zRange", key, 0, began, err) }()
+	members := make([]ScoredMember, 0)
+	err = c.view(func(txn *badger.Txn) error {
+		if start < 0 || stop < 0 {
+			start, stop = normalizeRange(start, stop, zsetLen(txn, c.scoped(key)))
+		}
+		if start < 0 {
+			start = 0
+		}
+
+		var rank int64
+		return zsetIterate(txn, c.scoped(key), reverse, func(member string, score float64) bool {
+			if rank > stop {
+				return false
+			}
+			if rank >= start {
+				members = append(members, ScoredMember{Member: member, Score: score})
+			}
+			rank++
+			return true
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// ZRank returns the rank of the given member of the sorted set of the given
+// key, by ascending score or by descending score when reverse is true, or
+// null if the member is not in the set.
+func (c *Client) ZRank(key string, member string, reverse bool) (_ interface{}, err error) {
+	start := time.Now()
+	defer func() { c.track("zRank", key, 0, start, err) }()
+	var rank interface{}
+	err = c.view(func(txn *badger.Txn) error {
+		var i int64
+		return zsetIterate(txn, c.scoped(key), reverse, func(m string, _ float64) bool {
+			if m == member {
+				rank = i
+				return false
+			}
+			i++
+			return true
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rank, nil
+}
+
+// zsetLen returns the number of members of the sorted set of the given key.
+func zsetLen(txn *badger.Txn, key string) int64 {
+	var length int64
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = zsetScorePrefix(key)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		length++
+	}
+	return length
+}
+
+// zsetIterate calls fn with the members of the sorted set of the given key,
+// ordered by score, until fn returns false.
+func zsetIterate(txn *badger.Txn, key string, reverse bool, fn func(member string, score float64) bool) error {
+	prefix := zsetScorePrefix(key)
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = prefix
+	opts.Reverse = reverse
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	seek := prefix
+	if reverse {
+		// Encoded scores are 8 bytes long and never start with 8 0xFF
+		// bytes, seeking there starts from the greatest score.
+		seek = append(append([]byte(nil), prefix...), 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)
+	}
+	for it.Seek(seek); it.Valid(); it.Next() {
+		k := it.Item().Key()[len(prefix):]
+		if !fn(string(k[8:]), decodeScore(k[:8])) {
+			return nil
+		}
+	}
+	return nil
+}